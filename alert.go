@@ -0,0 +1,271 @@
+package orderservice
+
+import (
+	"time"
+
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neodb"
+)
+
+// alert rule kinds
+const (
+	alertLarge        = "large"        // transfer of asset from or to a user wallet of at least the threshold
+	alertCounterparty = "counterparty" // transfer from a user wallet to an address it never transferred with
+	alertOutgoing     = "outgoing"     // any transfer from the address, registered by the user or not
+)
+
+// AlertRule user configured alert evaluated by the tx watcher on the confirmed orders
+type AlertRule struct {
+	ID         int64     `json:"id" xorm:"pk autoincr"`
+	UserID     string    `json:"userid" xorm:"notnull index"`
+	Kind       string    `json:"kind" xorm:"notnull"`
+	Address    string    `json:"address,omitempty" xorm:"notnull default '' index"` // all the user wallets if empty, required by outgoing
+	Asset      string    `json:"asset,omitempty" xorm:"notnull default ''"`         // large only
+	Threshold  string    `json:"threshold,omitempty" xorm:"notnull default ''"`     // large only
+	CreateTime time.Time `json:"createTime" xorm:"TIMESTAMP notnull created"`
+}
+
+// TableName xorm table name
+func (table *AlertRule) TableName() string {
+	return "neo_alert"
+}
+
+// validateAlertRule validate and normalize the alert rule of create alert request, the outgoing
+// rules watch a wallet or a watch-only subscription of the rule user
+func (service *HTTPServer) validateAlertRule(rule *AlertRule) (err error) {
+	switch rule.Kind {
+	case alertLarge:
+		if rule.Asset, err = service.validateAsset("asset", rule.Asset); err != nil {
+			return err
+		}
+
+		threshold, err := ParseDecimal(rule.Threshold)
+
+		if err != nil || threshold.IsZero() {
+			return newValidationError("threshold", "threshold must be a positive decimal")
+		}
+	case alertCounterparty, alertOutgoing:
+		if rule.Asset != "" || rule.Threshold != "" {
+			return newValidationError("kind", "asset and threshold are for %s rules only", alertLarge)
+		}
+	default:
+		return newValidationError("kind", "kind must be %s, %s or %s", alertLarge, alertCounterparty, alertOutgoing)
+	}
+
+	if rule.Address == "" {
+		if rule.Kind == alertOutgoing {
+			return newValidationError("address", "address required")
+		}
+
+		return nil
+	}
+
+	if rule.Address, err = validateAddress("address", rule.Address); err != nil || rule.Kind != alertOutgoing {
+		return err
+	}
+
+	owned, err := service.db.Where(`user_i_d = ? and "address" = ?`, rule.UserID, rule.Address).Exist(new(neodb.Wallet))
+
+	if err != nil || owned {
+		return err
+	}
+
+	subscribed, err := service.db.
+		Where("user_i_d = ? and kind = ? and target = ?", rule.UserID, subscribeAddress, rule.Address).
+		Exist(new(Subscription))
+
+	if err != nil {
+		return err
+	}
+
+	if !subscribed {
+		return newValidationError("address", "address %s is neither a wallet nor a subscription of user %s", rule.Address, rule.UserID)
+	}
+
+	return nil
+}
+
+// createAlertRule save the rule of userid, the rules of a user are limited to maxAlerts
+func (service *HTTPServer) createAlertRule(rule *AlertRule) error {
+	count, err := service.db.Where("user_i_d = ?", rule.UserID).Count(new(AlertRule))
+
+	if err != nil {
+		return err
+	}
+
+	if count >= int64(service.maxAlerts) {
+		return errConflict("user %s already has %d alert rules", rule.UserID, count)
+	}
+
+	_, err = service.db.Insert(rule)
+
+	return err
+}
+
+// listAlertRules the alert rules of userid in creation order
+func (service *HTTPServer) listAlertRules(userid string) ([]*AlertRule, error) {
+	rules := make([]*AlertRule, 0)
+
+	if err := service.db.Where("user_i_d = ?", userid).Asc("id").Find(&rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (service *HTTPServer) deleteAlertRule(userid string, id int64) error {
+	deleted, err := service.db.Where("user_i_d = ? and id = ?", userid, id).Delete(new(AlertRule))
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errNotFound("alert rule %d of user %s not found", id, userid)
+	}
+
+	return nil
+}
+
+// hasOutgoingAlert check if an outgoing alert watches address, so the transfers from
+// it are tracked as orders even if the address is not a registered wallet
+func (watcher *TxWatcher) hasOutgoingAlert(address string) (bool, error) {
+	return watcher.db.Where(`kind = ? and "address" = ?`, alertOutgoing, address).Exist(new(AlertRule))
+}
+
+// alert evaluate the alert rules on the confirmed orders and queue the notifications
+// of the rules fired within session, a rule fires at most once per order
+func (watcher *TxWatcher) alert(session *xorm.Session, orders []*neodb.Order) error {
+	for _, order := range orders {
+		wallets := make([]*neodb.Wallet, 0)
+
+		err := watcher.db.Where(`"address" = ? or "address" = ?`, order.From, order.To).Find(&wallets)
+
+		if err != nil {
+			return err
+		}
+
+		// the wallets of from and to registered by each user
+		owners := make(map[string]map[string]bool)
+		users := make([]string, 0, len(wallets))
+
+		for _, wallet := range wallets {
+			if owners[wallet.UserID] == nil {
+				owners[wallet.UserID] = make(map[string]bool)
+				users = append(users, wallet.UserID)
+			}
+
+			owners[wallet.UserID][wallet.Address] = true
+		}
+
+		rules := make([]*AlertRule, 0)
+
+		if err := watcher.db.Where(`kind = ? and "address" = ?`, alertOutgoing, order.From).Find(&rules); err != nil {
+			return err
+		}
+
+		if len(users) > 0 {
+			err := watcher.db.Where("kind <> ?", alertOutgoing).In("user_i_d", users).Find(&rules)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, rule := range rules {
+			address, fired, err := watcher.fires(rule, order, owners[rule.UserID])
+
+			if err != nil {
+				return err
+			}
+
+			if !fired {
+				continue
+			}
+
+			labels, err := watcher.labels.labels(rule.UserID, []string{order.From, order.To})
+
+			if err != nil {
+				return err
+			}
+
+			data := &PushData{
+				Address:      address,
+				Counterparty: order.From,
+				Asset:        order.Asset,
+				AssetName:    watcher.assets.name(order.Asset),
+				Value:        watcher.assets.formatValue(order.Asset, order.Value),
+				TX:           order.TX,
+				Threshold:    rule.Threshold,
+			}
+
+			if address == order.From {
+				data.Counterparty = order.To
+			}
+
+			data.Label = labels[data.Counterparty]
+
+			if err := watcher.queue(session, rule.UserID, address, alertEvents[rule.Kind], data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fires check if rule fires on order, returns the address of the rule the order is from or to,
+// change sent back to the sender fires no rule
+func (watcher *TxWatcher) fires(rule *AlertRule, order *neodb.Order, owned map[string]bool) (string, bool, error) {
+	watched := func(address string) bool {
+		return (rule.Address == "" || rule.Address == address) && owned[address]
+	}
+
+	if order.From == order.To {
+		return "", false, nil
+	}
+
+	switch rule.Kind {
+	case alertOutgoing:
+		return order.From, order.From == rule.Address, nil
+	case alertLarge:
+		if rule.Asset != order.Asset {
+			return "", false, nil
+		}
+
+		address := order.From
+
+		if !watched(address) {
+			if address = order.To; !watched(address) {
+				return "", false, nil
+			}
+		}
+
+		threshold, err := ParseDecimal(rule.Threshold)
+
+		if err != nil {
+			return "", false, nil
+		}
+
+		value, err := watcher.assets.parseValue(order.Asset, order.Value)
+
+		if err != nil {
+			return "", false, nil
+		}
+
+		return address, value.Cmp(threshold) >= 0, nil
+	case alertCounterparty:
+		if !watched(order.From) || owned[order.To] {
+			return "", false, nil
+		}
+
+		seen, err := watcher.db.
+			Where(`t_x <> ? and (("from" = ? and "to" = ?) or ("from" = ? and "to" = ?))`,
+				order.TX, order.From, order.To, order.To, order.From).
+			Exist(new(neodb.Order))
+
+		return order.From, !seen, err
+	}
+
+	return "", false, nil
+}
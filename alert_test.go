@@ -0,0 +1,55 @@
+package orderservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/inwecrypto/neodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertFires(t *testing.T) {
+	watcher := &TxWatcher{assets: newTestAssets(t)}
+	confirmTime := time.Now()
+
+	order := func(from string, to string, asset string, value string) *neodb.Order {
+		return &neodb.Order{TX: "tx", From: from, To: to, Asset: asset, Value: value, ConfirmTime: &confirmTime}
+	}
+
+	large := &AlertRule{Kind: alertLarge, Asset: gasAsset, Threshold: "10"}
+	largeOfB := &AlertRule{Kind: alertLarge, Address: "B", Asset: gasAsset, Threshold: "10"}
+	outgoing := &AlertRule{Kind: alertOutgoing, Address: "A"}
+
+	tests := []struct {
+		name    string
+		rule    *AlertRule
+		order   *neodb.Order
+		owned   map[string]bool
+		address string
+		fired   bool
+	}{
+		{"large sent", large, order("A", "B", gasAsset, "10"), map[string]bool{"A": true}, "A", true},
+		{"large received", large, order("B", "A", gasAsset, "12.5"), map[string]bool{"A": true}, "A", true},
+		{"large below threshold", large, order("A", "B", gasAsset, "9.99999999"), map[string]bool{"A": true}, "", false},
+		{"large other asset", large, order("A", "B", neoAsset, "100"), map[string]bool{"A": true}, "", false},
+		{"large not owned", large, order("C", "D", gasAsset, "100"), map[string]bool{"A": true}, "", false},
+		{"large change", large, order("A", "A", gasAsset, "100"), map[string]bool{"A": true}, "", false},
+		{"large of another wallet", largeOfB, order("A", "C", gasAsset, "100"), map[string]bool{"A": true, "B": true}, "", false},
+		{"large of the wallet", largeOfB, order("C", "B", gasAsset, "100"), map[string]bool{"A": true, "B": true}, "B", true},
+		{"outgoing", outgoing, order("A", "B", neoAsset, "1"), nil, "A", true},
+		{"outgoing incoming", outgoing, order("B", "A", neoAsset, "1"), nil, "", false},
+		{"outgoing change", outgoing, order("A", "A", neoAsset, "1"), nil, "", false},
+	}
+
+	for _, test := range tests {
+		address, fired, err := watcher.fires(test.rule, test.order, test.owned)
+
+		if assert.NoError(t, err, test.name) {
+			assert.Equal(t, test.fired, fired, test.name)
+
+			if test.fired {
+				assert.Equal(t, test.address, address, test.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package orderservice
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// maxAmountDecimals the max decimals of an asset, 18 like the common NEP-5 and ERC-20 tokens
+const maxAmountDecimals = 18
+
+// Amount fixed-point asset amount counted in the smallest units of the asset decimals,
+// a nil Units is zero
+type Amount struct {
+	Units    *big.Int
+	Decimals int
+}
+
+var pow10 = func() []*big.Int {
+	result := make([]*big.Int, maxAmountDecimals+1)
+
+	for i := range result {
+		result[i] = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(i)), nil)
+	}
+
+	return result
+}()
+
+// ParseAmount parse a non-negative decimal string, e.g. "12.5", with at most decimals
+// significant fraction digits. Signs, exponents and extra non-zero fraction digits are
+// rejected instead of being rounded.
+func ParseAmount(value string, decimals int) (Amount, error) {
+	if decimals < 0 || decimals > maxAmountDecimals {
+		return Amount{}, fmt.Errorf("invalid amount decimals %d", decimals)
+	}
+
+	integer := value
+	fraction := ""
+
+	if index := strings.IndexByte(value, '.'); index >= 0 {
+		integer = value[:index]
+		fraction = value[index+1:]
+	}
+
+	if integer == "" || !isDigits(integer) || (fraction == "" && strings.HasSuffix(value, ".")) || !isDigits(fraction) {
+		return Amount{}, fmt.Errorf("malformed amount %q", value)
+	}
+
+	if len(fraction) > decimals {
+		if strings.Trim(fraction[decimals:], "0") != "" {
+			return Amount{}, fmt.Errorf("amount %q exceeds %d decimals", value, decimals)
+		}
+
+		fraction = fraction[:decimals]
+	}
+
+	fraction += strings.Repeat("0", decimals-len(fraction))
+
+	units, ok := new(big.Int).SetString(integer+fraction, 10)
+
+	if !ok {
+		return Amount{}, fmt.Errorf("malformed amount %q", value)
+	}
+
+	return Amount{Units: units, Decimals: decimals}, nil
+}
+
+// ParseDecimal parse a non-negative decimal string keeping exactly the fraction digits it carries,
+// for amounts not bound to an asset, e.g. thresholds compared against amounts of any asset
+func ParseDecimal(value string) (Amount, error) {
+	decimals := 0
+
+	if index := strings.IndexByte(value, '.'); index >= 0 {
+		decimals = len(strings.TrimRight(value[index+1:], "0"))
+	}
+
+	if decimals > maxAmountDecimals {
+		return Amount{}, fmt.Errorf("amount %q exceeds %d decimals", value, maxAmountDecimals)
+	}
+
+	return ParseAmount(value, decimals)
+}
+
+func isDigits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// units get amount units, zero for a nil Units
+func (amount Amount) units() *big.Int {
+	if amount.Units == nil {
+		return new(big.Int)
+	}
+
+	return amount.Units
+}
+
+// String format amount as decimal string without trailing fraction zeros
+func (amount Amount) String() string {
+	units := amount.units()
+
+	sign := ""
+
+	if units.Sign() < 0 {
+		sign = "-"
+	}
+
+	integer, fraction := new(big.Int).QuoRem(new(big.Int).Abs(units), pow10[amount.Decimals], new(big.Int))
+
+	result := sign + integer.String()
+
+	if fraction.Sign() != 0 {
+		digits := fraction.String()
+
+		result += "." + strings.TrimRight(strings.Repeat("0", amount.Decimals-len(digits))+digits, "0")
+	}
+
+	return result
+}
+
+// IsPositive check if amount greater than zero
+func (amount Amount) IsPositive() bool {
+	return amount.units().Sign() > 0
+}
+
+// IsZero check if amount is zero
+func (amount Amount) IsZero() bool {
+	return amount.units().Sign() == 0
+}
+
+// Add add other to amount, both must have the same decimals
+func (amount Amount) Add(other Amount) (Amount, error) {
+	if amount.Decimals != other.Decimals {
+		return Amount{}, fmt.Errorf("add amounts with different decimals %d and %d", amount.Decimals, other.Decimals)
+	}
+
+	return Amount{Units: new(big.Int).Add(amount.units(), other.units()), Decimals: amount.Decimals}, nil
+}
+
+// Sub subtract other from amount, both must have the same decimals
+func (amount Amount) Sub(other Amount) (Amount, error) {
+	if amount.Decimals != other.Decimals {
+		return Amount{}, fmt.Errorf("sub amounts with different decimals %d and %d", amount.Decimals, other.Decimals)
+	}
+
+	return Amount{Units: new(big.Int).Sub(amount.units(), other.units()), Decimals: amount.Decimals}, nil
+}
+
+// Cmp compare amount with other, returns -1, 0 or +1, the amounts may have different decimals
+func (amount Amount) Cmp(other Amount) int {
+	x := new(big.Int).Set(amount.units())
+	y := new(big.Int).Set(other.units())
+
+	if amount.Decimals < other.Decimals {
+		x.Mul(x, pow10[other.Decimals-amount.Decimals])
+	} else {
+		y.Mul(y, pow10[amount.Decimals-other.Decimals])
+	}
+
+	return x.Cmp(y)
+}
@@ -0,0 +1,67 @@
+package orderservice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAmount(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		decimals int
+		expect   string
+		invalid  bool
+	}{
+		{"0", 0, "0", false},
+		{"007.50", 8, "7.5", false},
+		{"12.30000001", 8, "12.30000001", false},
+		{"1.000000000000000001", 18, "1.000000000000000001", false},
+		{"100000000000.000000000000000001", 18, "100000000000.000000000000000001", false},
+		{"1.100", 1, "1.1", false},
+		{"1.11", 1, "", true},
+		{"1.", 8, "", true},
+		{".5", 8, "", true},
+		{"-1", 8, "", true},
+		{"1e8", 8, "", true},
+		{"1", 19, "", true},
+	} {
+		amount, err := ParseAmount(test.value, test.decimals)
+
+		if test.invalid {
+			assert.Error(t, err, test.value)
+			continue
+		}
+
+		if assert.NoError(t, err, test.value) {
+			assert.Equal(t, test.expect, amount.String())
+		}
+	}
+}
+
+func TestAmountSum(t *testing.T) {
+	sum := Amount{Decimals: 18}
+
+	for _, value := range []string{"9.5", "9.5", "0.000000000000000001"} {
+		amount, err := ParseAmount(value, 18)
+
+		if assert.NoError(t, err) {
+			sum, err = sum.Add(amount)
+			assert.NoError(t, err)
+		}
+	}
+
+	assert.Equal(t, "19.000000000000000001", sum.String())
+
+	_, err := sum.Add(Amount{Decimals: 8})
+	assert.Error(t, err)
+
+	diff, err := Amount{Decimals: 18}.Sub(sum)
+	assert.NoError(t, err)
+	assert.Equal(t, "-19.000000000000000001", diff.String())
+
+	threshold, err := ParseDecimal("19")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sum.Cmp(threshold))
+	assert.Equal(t, -1, threshold.Cmp(sum))
+}
@@ -0,0 +1,311 @@
+package orderservice
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dynamicgo/config"
+	"github.com/dynamicgo/slf4go"
+	"github.com/go-xorm/xorm"
+)
+
+// asset types
+const (
+	assetTypeGlobal = "global" // utxo global asset, identified by the register tx id
+	assetTypeNEP5   = "nep5"   // nep5 token, identified by the contract script hash
+)
+
+// Asset registered asset metadata
+type Asset struct {
+	ID         int64     `json:"-" xorm:"pk autoincr"`
+	Asset      string    `json:"asset" xorm:"notnull unique"`
+	Symbol     string    `json:"symbol" xorm:"notnull"`
+	Name       string    `json:"name" xorm:"notnull"`
+	Decimals   int       `json:"decimals" xorm:"notnull"`
+	Type       string    `json:"type" xorm:"notnull"`
+	CreateTime time.Time `json:"-" xorm:"TIMESTAMP notnull created"`
+	UpdateTime time.Time `json:"-" xorm:"TIMESTAMP notnull updated"`
+}
+
+// TableName xorm table name
+func (table *Asset) TableName() string {
+	return "neo_asset"
+}
+
+// builtinAssets assets known without any configuration
+var builtinAssets = []*Asset{
+	{Asset: neoAsset, Symbol: "NEO", Name: "NEO", Decimals: 0, Type: assetTypeGlobal},
+	{Asset: gasAsset, Symbol: "GAS", Name: "NEO GAS", Decimals: 8, Type: assetTypeGlobal},
+	{Asset: rpxAsset, Symbol: "RPX", Name: "Red Pulse Token", Decimals: 8, Type: assetTypeNEP5},
+	{Asset: dbcAsset, Symbol: "DBC", Name: "DeepBrain Coin", Decimals: 8, Type: assetTypeNEP5},
+	{Asset: qlcAsset, Symbol: "QLC", Name: "Qlink Token", Decimals: 8, Type: assetTypeNEP5},
+	{Asset: tncAsset, Symbol: "TNC", Name: "Trinity Network Credit", Decimals: 8, Type: assetTypeNEP5},
+	{Asset: ontAsset, Symbol: "ONT", Name: "Ontology Token", Decimals: 8, Type: assetTypeNEP5},
+}
+
+// normalizeAssetID lower case the asset id and make sure it has 0x prefix
+func normalizeAssetID(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+
+	if !strings.HasPrefix(id, "0x") {
+		id = "0x" + id
+	}
+
+	return id
+}
+
+// validate check and normalize asset fields
+func (asset *Asset) validate() error {
+	asset.Asset = normalizeAssetID(asset.Asset)
+	asset.Symbol = strings.TrimSpace(asset.Symbol)
+	asset.Name = strings.TrimSpace(asset.Name)
+
+	var idLen int
+
+	switch asset.Type {
+	case assetTypeGlobal:
+		idLen = 64
+	case assetTypeNEP5:
+		idLen = 40
+	default:
+		return fmt.Errorf("asset type must be %s or %s", assetTypeGlobal, assetTypeNEP5)
+	}
+
+	if len(asset.Asset) != idLen+2 || !isHex(asset.Asset[2:]) {
+		return fmt.Errorf("%s asset id must be %d hex chars", asset.Type, idLen)
+	}
+
+	if asset.Symbol == "" {
+		return fmt.Errorf("asset symbol required")
+	}
+
+	if asset.Name == "" {
+		asset.Name = asset.Symbol
+	}
+
+	if asset.Decimals < 0 || asset.Decimals > maxAmountDecimals {
+		return fmt.Errorf("asset decimals must between 0 and %d", maxAmountDecimals)
+	}
+
+	return nil
+}
+
+func isHex(value string) bool {
+	for _, c := range value {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// assetRegistry in memory view of the neo_asset table
+type assetRegistry struct {
+	sync.RWMutex
+	slf4go.Logger
+	db              *xorm.Engine
+	assets          map[string]*Asset
+	refreshTime     time.Time
+	refreshDuration time.Duration
+}
+
+func newAssetRegistry(cnf *config.Config, db *xorm.Engine) *assetRegistry {
+	registry := &assetRegistry{
+		Logger:          slf4go.Get("asset-registry"),
+		db:              db,
+		assets:          make(map[string]*Asset),
+		refreshDuration: cnf.GetDuration("order.asset.refresh", time.Minute),
+	}
+
+	for _, asset := range builtinAssets {
+		registry.assets[asset.Asset] = asset
+	}
+
+	return registry
+}
+
+// seed insert the configured assets which are not registered yet
+func (registry *assetRegistry) seed(cnf *config.Config) error {
+	var assets []*Asset
+
+	if !cnf.Has("order.assets") {
+		return nil
+	}
+
+	if err := cnf.GetObject("order.assets", &assets); err != nil {
+		return err
+	}
+
+	for _, asset := range assets {
+		if err := asset.validate(); err != nil {
+			return fmt.Errorf("config asset %s error, %s", asset.Asset, err)
+		}
+
+		exists, err := registry.db.Where("asset = ?", asset.Asset).Exist(new(Asset))
+
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			continue
+		}
+
+		if _, err := registry.db.Insert(asset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refresh reload registered assets from db
+func (registry *assetRegistry) refresh() error {
+	assets := make([]*Asset, 0)
+
+	if err := registry.db.Find(&assets); err != nil {
+		return err
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, asset := range assets {
+		registry.assets[asset.Asset] = asset
+	}
+
+	registry.refreshTime = time.Now()
+
+	return nil
+}
+
+// get get asset by id, reload the registry on miss at most once per refresh duration
+func (registry *assetRegistry) get(id string) (*Asset, bool) {
+	id = normalizeAssetID(id)
+
+	registry.RLock()
+	asset, ok := registry.assets[id]
+	refreshTime := registry.refreshTime
+	registry.RUnlock()
+
+	if ok || time.Since(refreshTime) < registry.refreshDuration {
+		return asset, ok
+	}
+
+	if err := registry.refresh(); err != nil {
+		registry.ErrorF("refresh asset registry error, %s", err)
+		return nil, false
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	asset, ok = registry.assets[id]
+
+	return asset, ok
+}
+
+// list all known assets ordered by symbol
+func (registry *assetRegistry) list() ([]*Asset, error) {
+	if err := registry.refresh(); err != nil {
+		return nil, err
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	assets := make([]*Asset, 0, len(registry.assets))
+
+	for _, asset := range registry.assets {
+		assets = append(assets, asset)
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Symbol < assets[j].Symbol
+	})
+
+	return assets, nil
+}
+
+// save insert or update asset and update the registry
+func (registry *assetRegistry) save(asset *Asset) error {
+	if err := asset.validate(); err != nil {
+		return err
+	}
+
+	exists, err := registry.db.Where("asset = ?", asset.Asset).Exist(new(Asset))
+
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		_, err = registry.db.
+			Where("asset = ?", asset.Asset).
+			Cols("symbol", "name", "decimals", "type").
+			Update(asset)
+	} else {
+		_, err = registry.db.Insert(asset)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	registry.Lock()
+	registry.assets[asset.Asset] = asset
+	registry.Unlock()
+
+	return nil
+}
+
+// name get asset display name
+func (registry *assetRegistry) name(id string) string {
+	asset, ok := registry.get(id)
+
+	if !ok {
+		return "unknown asset"
+	}
+
+	return asset.Name
+}
+
+// symbol get asset ticker symbol, empty for unknown assets
+func (registry *assetRegistry) symbol(id string) string {
+	if asset, ok := registry.get(id); ok {
+		return asset.Symbol
+	}
+
+	return ""
+}
+
+// decimals get asset decimals, unknown assets are parsed with the max decimals so no
+// fraction digits of their values are rejected
+func (registry *assetRegistry) decimals(id string) int {
+	if asset, ok := registry.get(id); ok {
+		return asset.Decimals
+	}
+
+	return maxAmountDecimals
+}
+
+// parseValue parse value as amount of asset
+func (registry *assetRegistry) parseValue(id string, value string) (Amount, error) {
+	return ParseAmount(value, registry.decimals(id))
+}
+
+// formatValue normalize value to the asset decimals without trailing zeros
+func (registry *assetRegistry) formatValue(id string, value string) string {
+	amount, err := registry.parseValue(id, value)
+
+	if err != nil {
+		registry.WarnF("format asset %s value %s error, %s", id, value, err)
+		return value
+	}
+
+	return amount.String()
+}
@@ -0,0 +1,221 @@
+package orderservice
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dynamicgo/config"
+	"github.com/gin-gonic/gin"
+	"github.com/inwecrypto/neodb"
+)
+
+const principalKey = "principal"
+
+const apiKeyHeader = "X-API-Key"
+
+const adminTokenHeader = "X-Admin-Token"
+
+// principal authenticated api caller
+type principal struct {
+	UserID string
+	Admin  bool
+}
+
+// apiKey configured api key bound to a userid
+type apiKey struct {
+	Key    string `json:"key"`
+	UserID string `json:"userid"`
+	Admin  bool   `json:"admin"`
+}
+
+// authenticator api key, admin token and jwt bearer token authenticator
+type authenticator struct {
+	enabled    bool
+	apiKeys    []*apiKey
+	adminToken string
+	verifier   *jwtVerifier
+}
+
+// newAuthenticator create the authenticator, enabled unless order.auth.enabled is explicitly false
+func newAuthenticator(cnf *config.Config) (*authenticator, error) {
+	auth := &authenticator{
+		enabled:    cnf.GetBool("order.auth.enabled", true),
+		adminToken: cnf.GetString("order.admin.token", ""),
+		verifier: &jwtVerifier{
+			secret:   []byte(cnf.GetString("order.auth.jwt.secret", "")),
+			issuer:   cnf.GetString("order.auth.jwt.issuer", ""),
+			audience: cnf.GetString("order.auth.jwt.audience", ""),
+			leeway:   cnf.GetDuration("order.auth.jwt.leeway", 30*time.Second),
+		},
+	}
+
+	if cnf.Has("order.auth.apikeys") {
+		if err := cnf.GetObject("order.auth.apikeys", &auth.apiKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	if path := cnf.GetString("order.auth.jwt.publickey", ""); path != "" {
+		key, err := loadRSAPublicKey(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("load jwt public key error, %s", err)
+		}
+
+		auth.verifier.publicKey = key
+	}
+
+	if auth.enabled && len(auth.apiKeys) == 0 && auth.adminToken == "" &&
+		len(auth.verifier.secret) == 0 && auth.verifier.publicKey == nil {
+		return nil, fmt.Errorf("auth enabled without api keys, admin token or jwt keys, configure them or set order.auth.enabled to false")
+	}
+
+	return auth, nil
+}
+
+// authenticate resolve the request credentials, returns nil principal without credentials
+func (auth *authenticator) authenticate(request *http.Request) (*principal, error) {
+	if token := request.Header.Get(adminTokenHeader); token != "" {
+		if auth.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(auth.adminToken)) == 1 {
+			return &principal{Admin: true}, nil
+		}
+
+		return nil, fmt.Errorf("invalid admin token")
+	}
+
+	if key := request.Header.Get(apiKeyHeader); key != "" {
+		for _, apiKey := range auth.apiKeys {
+			if subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) == 1 {
+				return &principal{UserID: apiKey.UserID, Admin: apiKey.Admin}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	authorization := request.Header.Get("Authorization")
+
+	if authorization == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, fmt.Errorf("unsupported authorization scheme")
+	}
+
+	claims, err := auth.verifier.verify(strings.TrimPrefix(authorization, "Bearer "))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &principal{UserID: claims.Subject, Admin: claims.Admin}, nil
+}
+
+// authenticate middleware reject unauthenticated requests when auth enabled, when disabled
+// the valid credentials still bind the caller, e.g. the admin token for the admin api
+func (service *HTTPServer) authenticate(ctx *gin.Context) {
+	caller, err := service.auth.authenticate(ctx.Request)
+
+	if !service.auth.enabled {
+		if err == nil && caller != nil {
+			ctx.Set(principalKey, caller)
+		}
+
+		ctx.Next()
+		return
+	}
+
+	if err != nil {
+		service.abort(ctx, errUnauthorized("%s", err))
+		return
+	}
+
+	if caller == nil {
+		service.abort(ctx, errUnauthorized("api key or bearer token required"))
+		return
+	}
+
+	ctx.Set(principalKey, caller)
+
+	ctx.Next()
+}
+
+// principal get the authenticated caller, nil when auth disabled and no credentials given
+func (service *HTTPServer) principal(ctx *gin.Context) *principal {
+	if value, ok := ctx.Get(principalKey); ok {
+		return value.(*principal)
+	}
+
+	return nil
+}
+
+// authorizeUser check the caller can act on behalf of userid
+func (service *HTTPServer) authorizeUser(ctx *gin.Context, userid string) error {
+	caller := service.principal(ctx)
+
+	if !service.auth.enabled || caller.Admin || caller.UserID == userid {
+		return nil
+	}
+
+	return errForbidden("not allowed to access user %s", userid)
+}
+
+// authorizeOrder check the caller owns the from or to wallet of order
+func (service *HTTPServer) authorizeOrder(ctx *gin.Context, order *Order) error {
+	caller := service.principal(ctx)
+
+	if !service.auth.enabled || caller.Admin {
+		return nil
+	}
+
+	exists, err := service.db.
+		Where(`("address" = ? or "address" = ?) and user_i_d = ?`, order.From, order.To, caller.UserID).
+		Exist(new(neodb.Wallet))
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return errForbidden("neither from nor to address is registered by user %s", caller.UserID)
+	}
+
+	return nil
+}
+
+// authorizeInvoice check the caller owns the invoice recipient address as a wallet
+func (service *HTTPServer) authorizeInvoice(ctx *gin.Context, invoice *Invoice) error {
+	caller := service.principal(ctx)
+
+	if !service.auth.enabled || caller.Admin {
+		return nil
+	}
+
+	exists, err := service.db.
+		Where(`"address" = ? and user_i_d = ?`, invoice.Address, caller.UserID).
+		Exist(new(neodb.Wallet))
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return errForbidden("address %s is not registered by user %s", invoice.Address, caller.UserID)
+	}
+
+	return nil
+}
+
+// adminOnly allow admin callers only, admin api keys, jwt or the configured admin token
+func (service *HTTPServer) adminOnly(ctx *gin.Context) {
+	if caller := service.principal(ctx); caller == nil || !caller.Admin {
+		service.abort(ctx, errForbidden("admin required"))
+		return
+	}
+
+	ctx.Next()
+}
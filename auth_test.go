@@ -0,0 +1,107 @@
+package orderservice
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfig(t *testing.T, source string) *config.Config {
+	cnf, err := config.New([]byte(source))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cnf
+}
+
+func TestAuthenticatorEnabledByDefault(t *testing.T) {
+	_, err := newAuthenticator(newTestConfig(t, `{}`))
+	assert.Error(t, err)
+
+	auth, err := newAuthenticator(newTestConfig(t, `{"order": {"admin": {"token": "secret"}}}`))
+
+	if assert.NoError(t, err) {
+		assert.True(t, auth.enabled)
+	}
+
+	auth, err = newAuthenticator(newTestConfig(t, `{"order": {"auth": {"enabled": false}}}`))
+
+	if assert.NoError(t, err) {
+		assert.False(t, auth.enabled)
+	}
+}
+
+func TestAuthenticateAdminToken(t *testing.T) {
+	auth, err := newAuthenticator(newTestConfig(t, `{"order": {
+		"admin": {"token": "secret"},
+		"auth": {"apikeys": [{"key": "k1", "userid": "alice"}]}
+	}}`))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	request := httptest.NewRequest("POST", "/asset", nil)
+	request.Header.Set(adminTokenHeader, "secret")
+
+	caller, err := auth.authenticate(request)
+
+	if assert.NoError(t, err) && assert.NotNil(t, caller) {
+		assert.True(t, caller.Admin)
+	}
+
+	request.Header.Set(adminTokenHeader, "wrong")
+
+	_, err = auth.authenticate(request)
+	assert.Error(t, err)
+
+	request = httptest.NewRequest("GET", "/wallets/alice", nil)
+	request.Header.Set(apiKeyHeader, "k1")
+
+	caller, err = auth.authenticate(request)
+
+	if assert.NoError(t, err) && assert.NotNil(t, caller) {
+		assert.Equal(t, "alice", caller.UserID)
+		assert.False(t, caller.Admin)
+	}
+}
+
+func signTestJWT(secret string, claims string) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWTExpiration(t *testing.T) {
+	verifier := &jwtVerifier{secret: []byte("secret"), leeway: 30 * time.Second}
+
+	now := time.Now().Unix()
+
+	claims, err := verifier.verify(signTestJWT("secret", fmt.Sprintf(`{"sub":"alice","exp":%d}`, now+60)))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice", claims.Subject)
+	}
+
+	_, err = verifier.verify(signTestJWT("secret", fmt.Sprintf(`{"sub":"alice","exp":%d}`, now-60)))
+	assert.Error(t, err)
+
+	_, err = verifier.verify(signTestJWT("secret", `{"sub":"alice"}`))
+	assert.Error(t, err)
+
+	_, err = verifier.verify(signTestJWT("wrong", fmt.Sprintf(`{"sub":"alice","exp":%d}`, now+60)))
+	assert.Error(t, err)
+}
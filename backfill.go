@@ -0,0 +1,256 @@
+package orderservice
+
+import (
+	"time"
+
+	"github.com/inwecrypto/neodb"
+)
+
+// backfill job status
+const (
+	backfillRunning = "running"
+	backfillDone    = "done"
+	backfillFailed  = "failed"
+)
+
+// WalletBackfill progress of the job creating the historical orders of an address
+type WalletBackfill struct {
+	ID         int64      `json:"-" xorm:"pk autoincr"`
+	Address    string     `json:"address" xorm:"notnull unique"`
+	Status     string     `json:"status" xorm:"notnull"`
+	Block      int64      `json:"block" xorm:"notnull default 0"`   // block of the last scanned transfer
+	Scanned    int64      `json:"scanned" xorm:"notnull default 0"` // transfers scanned
+	Created    int64      `json:"created" xorm:"notnull default 0"` // orders created or confirmed
+	Error      string     `json:"error,omitempty" xorm:"TEXT"`
+	StartTime  time.Time  `json:"startTime" xorm:"TIMESTAMP notnull"`
+	FinishTime *time.Time `json:"finishTime,omitempty" xorm:"TIMESTAMP"`
+	UpdateTime time.Time  `json:"updateTime" xorm:"TIMESTAMP notnull"`
+}
+
+// TableName xorm table name
+func (table *WalletBackfill) TableName() string {
+	return "neo_wallet_backfill"
+}
+
+// backfillSource load the transfers of address with id greater than after, ordered by id
+type backfillSource func(address string, after int64, limit int) ([]*neodb.Tx, error)
+
+func (service *HTTPServer) utxoTransfers(address string, after int64, limit int) ([]*neodb.Tx, error) {
+	var txs []*neodb.Tx
+
+	err := service.db.
+		Where(`("from" = ? or "to" = ?) and id > ?`, address, address, after).
+		Asc("id").Limit(limit).Find(&txs)
+
+	return txs, err
+}
+
+func (service *HTTPServer) nep5Transfers(address string, after int64, limit int) ([]*neodb.Tx, error) {
+	var nep5Txs []*NEP5Tx
+
+	err := service.db.
+		Where(`("from" = ? or "to" = ?) and id > ?`, address, address, after).
+		Asc("id").Limit(limit).Find(&nep5Txs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]*neodb.Tx, 0, len(nep5Txs))
+
+	for _, nep5Tx := range nep5Txs {
+		txs = append(txs, nep5Tx.neoTx())
+	}
+
+	return txs, nil
+}
+
+// getBackfill get the backfill progress of address
+func (service *HTTPServer) getBackfill(address string) (*WalletBackfill, error) {
+	backfill := new(WalletBackfill)
+
+	ok, err := service.db.Where(`"address" = ?`, address).Get(backfill)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errNotFound("backfill of %s not found", address)
+	}
+
+	return backfill, nil
+}
+
+// startBackfill start the backfill job of address in background, a job still running,
+// not stale, is not restarted and errConflict returned
+func (service *HTTPServer) startBackfill(address string) (*WalletBackfill, error) {
+	now := time.Now()
+
+	_, err := service.db.Exec(
+		`INSERT INTO neo_wallet_backfill ("address", status, start_time, update_time) VALUES (?, ?, ?, ?) ON CONFLICT ("address") DO NOTHING`,
+		address, backfillDone, formatDBTime(now, service.db.DatabaseTZ), formatDBTime(now, service.db.DatabaseTZ),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	backfill := &WalletBackfill{
+		Address:    address,
+		Status:     backfillRunning,
+		StartTime:  now,
+		UpdateTime: now,
+	}
+
+	claimed, err := service.db.
+		Where(`"address" = ? and (status <> ? or update_time < ?)`,
+			address, backfillRunning, formatDBTime(now.Add(-service.backfillStale), service.db.DatabaseTZ)).
+		Cols("status", "block", "scanned", "created", "error", "start_time", "finish_time", "update_time").
+		Update(backfill)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claimed == 0 {
+		return nil, errConflict("backfill of %s is running", address)
+	}
+
+	go service.runBackfill(backfill)
+
+	return backfill, nil
+}
+
+// runBackfill the backfilled orders are history, they deliberately bypass the post-confirm
+// path of the tx watcher: no notification or alert is queued and no invoice is matched,
+// only the stats of the address are rebuilt from its orders
+func (service *HTTPServer) runBackfill(backfill *WalletBackfill) {
+	service.DebugF("backfill %s started", backfill.Address)
+
+	var err error
+
+	for _, source := range []backfillSource{service.utxoTransfers, service.nep5Transfers} {
+		if err = service.backfillFrom(backfill, source); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		// the backfilled orders are not seen by the tx watcher
+		err = rebuildStats(service.db, backfill.Address)
+	}
+
+	finishTime := time.Now()
+
+	backfill.Status = backfillDone
+	backfill.FinishTime = &finishTime
+	backfill.UpdateTime = finishTime
+
+	if err != nil {
+		service.ErrorF("backfill %s error, %s", backfill.Address, err)
+
+		backfill.Status = backfillFailed
+		backfill.Error = err.Error()
+	}
+
+	if err := service.saveBackfill(backfill, "status", "error", "finish_time", "update_time"); err != nil {
+		service.ErrorF("save backfill %s error, %s", backfill.Address, err)
+	}
+
+	service.DebugF("backfill %s %s, scanned %d, created %d", backfill.Address, backfill.Status, backfill.Scanned, backfill.Created)
+}
+
+// backfillFrom create the orders of all the transfers of source, saving the progress after each batch
+func (service *HTTPServer) backfillFrom(backfill *WalletBackfill, source backfillSource) error {
+	var after int64
+
+	for {
+		txs, err := source(backfill.Address, after, service.backfillBatch)
+
+		if err != nil {
+			return err
+		}
+
+		if len(txs) == 0 {
+			return nil
+		}
+
+		for _, tx := range txs {
+			created, err := service.backfillTx(tx)
+
+			if err != nil {
+				return err
+			}
+
+			if created {
+				backfill.Created++
+			}
+
+			after = tx.ID
+			backfill.Scanned++
+
+			if int64(tx.Block) > backfill.Block {
+				backfill.Block = int64(tx.Block)
+			}
+		}
+
+		backfill.UpdateTime = time.Now()
+
+		if err := service.saveBackfill(backfill, "block", "scanned", "created", "update_time"); err != nil {
+			return err
+		}
+	}
+}
+
+func (service *HTTPServer) saveBackfill(backfill *WalletBackfill, cols ...string) error {
+	_, err := service.db.Where(`"address" = ?`, backfill.Address).Cols(cols...).Update(backfill)
+
+	return err
+}
+
+// backfillTx create the confirmed order of tx, or confirm the pending one, returns false
+// if the order already exists and is confirmed so re-running the backfill is a no-op
+func (service *HTTPServer) backfillTx(tx *neodb.Tx) (bool, error) {
+	order := &neodb.Order{
+		TX:          tx.TX,
+		From:        tx.From,
+		To:          tx.To,
+		Asset:       tx.Asset,
+		Value:       service.assets.formatValue(tx.Asset, tx.Value),
+		Block:       int64(tx.Block),
+		CreateTime:  tx.CreateTime,
+		ConfirmTime: &tx.CreateTime,
+	}
+
+	existing := new(neodb.Order)
+
+	ok, err := service.db.
+		Where(`t_x = ? and asset = ? and "from" = ? and "to" = ? and "value" = ?`,
+			order.TX, order.Asset, order.From, order.To, order.Value).
+		Get(existing)
+
+	if err != nil {
+		return false, err
+	}
+
+	if ok {
+		if existing.ConfirmTime != nil {
+			return false, nil
+		}
+
+		_, err := service.db.ID(existing.ID).Cols("confirm_time", "block").Update(order)
+
+		return err == nil, err
+	}
+
+	session := service.db.NewSession()
+
+	defer session.Close()
+
+	if _, err := session.NoAutoTime().Insert(order); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,64 @@
+package orderservice
+
+import (
+	"sort"
+
+	"github.com/inwecrypto/neodb"
+)
+
+// Balance address balance of one global asset
+type Balance struct {
+	Asset     string `json:"asset"`
+	AssetName string `json:"assetName"`
+	Value     string `json:"value"`
+}
+
+// getBalances sum the unspent utxos of address per asset
+func (service *HTTPServer) getBalances(address string) ([]*Balance, error) {
+
+	service.DebugF("get address(%s) balances", address)
+
+	utxos := make([]*neodb.UTXO, 0)
+
+	err := service.db.Where("address = ? and spent_block = -1", address).Find(&utxos)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]Amount)
+
+	for _, utxo := range utxos {
+		value, err := service.assets.parseValue(utxo.Asset, utxo.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sum, ok := sums[utxo.Asset]
+
+		if !ok {
+			sum = Amount{Decimals: value.Decimals}
+		}
+
+		if sums[utxo.Asset], err = sum.Add(value); err != nil {
+			return nil, err
+		}
+	}
+
+	balances := make([]*Balance, 0, len(sums))
+
+	for asset, sum := range sums {
+		balances = append(balances, &Balance{
+			Asset:     asset,
+			AssetName: service.assets.name(asset),
+			Value:     sum.String(),
+		})
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Asset < balances[j].Asset
+	})
+
+	return balances, nil
+}
@@ -0,0 +1,131 @@
+package orderservice
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neo-order-service/neo"
+)
+
+// WalletChallenge nonce issued to prove the ownership of an address before registering it
+type WalletChallenge struct {
+	ID         int64     `json:"-" xorm:"pk autoincr"`
+	Nonce      string    `json:"nonce" xorm:"notnull unique"`
+	UserID     string    `json:"userid" xorm:"notnull"`
+	Address    string    `json:"address" xorm:"notnull"`
+	ExpireTime time.Time `json:"expireTime" xorm:"TIMESTAMP notnull"`
+	CreateTime time.Time `json:"-" xorm:"TIMESTAMP notnull created"`
+}
+
+// TableName xorm table name
+func (table *WalletChallenge) TableName() string {
+	return "neo_wallet_challenge"
+}
+
+// WalletProof signature of the challenge nonce by the address key
+type WalletProof struct {
+	Nonce     string `json:"nonce" binding:"required"`
+	PublicKey string `json:"publicKey" binding:"required"` // hex encoded secp256r1 public key
+	Signature string `json:"signature" binding:"required"` // hex encoded SHA256withECDSA signature of the nonce
+}
+
+// createChallenge issue a new nonce for userid to register address
+func (service *HTTPServer) createChallenge(userid string, address string) (*WalletChallenge, error) {
+	if err := service.purgeChallenges(); err != nil {
+		service.WarnF("purge expired challenges error :%s", err)
+	}
+
+	buff := make([]byte, 32)
+
+	if _, err := rand.Read(buff); err != nil {
+		return nil, err
+	}
+
+	challenge := &WalletChallenge{
+		Nonce:      hex.EncodeToString(buff),
+		UserID:     userid,
+		Address:    address,
+		ExpireTime: time.Now().Add(service.challengeDuration),
+	}
+
+	if _, err := service.db.Insert(challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// verifyProof check proof signs a live challenge of userid and address with the address key,
+// the challenge is consumed only once the signature is verified
+func (service *HTTPServer) verifyProof(userid string, address string, proof *WalletProof) error {
+	if proof == nil {
+		return newValidationError("nonce", "ownership proof required")
+	}
+
+	live := func() *xorm.Session {
+		return service.db.
+			Where("nonce = ? and user_i_d = ? and address = ? and expire_time > ?",
+				proof.Nonce, userid, address, formatDBTime(time.Now(), service.db.DatabaseTZ))
+	}
+
+	exists, err := live().Exist(new(WalletChallenge))
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return newValidationError("nonce", "challenge not found or expired")
+	}
+
+	publicKey, err := hex.DecodeString(strings.TrimPrefix(proof.PublicKey, "0x"))
+
+	if err != nil {
+		return newValidationError("publicKey", "public key must be hex string")
+	}
+
+	key, err := neo.ParsePublicKey(publicKey)
+
+	if err != nil {
+		return newValidationError("publicKey", "%s", err)
+	}
+
+	if neo.PublicKeyAddress(key) != address {
+		return newValidationError("publicKey", "public key does not match address %s", address)
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(proof.Signature, "0x"))
+
+	if err != nil {
+		return newValidationError("signature", "signature must be hex string")
+	}
+
+	if !neo.VerifySignature(key, []byte(proof.Nonce), signature) {
+		return newValidationError("signature", "invalid signature")
+	}
+
+	// the delete decides between concurrent proofs of the same nonce
+	deleted, err := live().Delete(new(WalletChallenge))
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return newValidationError("nonce", "challenge not found or expired")
+	}
+
+	return nil
+}
+
+// purgeChallenges remove expired challenges
+func (service *HTTPServer) purgeChallenges() error {
+	_, err := service.db.
+		Where("expire_time <= ?", formatDBTime(time.Now(), service.db.DatabaseTZ)).
+		Delete(new(WalletChallenge))
+
+	return err
+}
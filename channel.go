@@ -0,0 +1,104 @@
+package orderservice
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ChannelToken confirmation token sent to an email or telegram target, the target is stored in
+// the wallet settings of the user only once the token is presented back
+type ChannelToken struct {
+	ID         int64     `json:"-" xorm:"pk autoincr"`
+	UserID     string    `json:"userid" xorm:"notnull unique(user_channel_target)"`
+	Channel    string    `json:"channel" xorm:"notnull unique(user_channel_target)"`
+	Target     string    `json:"target" xorm:"notnull unique(user_channel_target)"`
+	Token      string    `json:"-" xorm:"notnull"`
+	ExpireTime time.Time `json:"expireTime" xorm:"TIMESTAMP notnull"`
+}
+
+// TableName xorm table name
+func (table *ChannelToken) TableName() string {
+	return "neo_channel_token"
+}
+
+// sendChannelToken issue a new confirmation token of the target of channel for userid and send
+// it to the target, the previous token of the target is replaced
+func (service *HTTPServer) sendChannelToken(userid string, channel string, target string) (*ChannelToken, error) {
+	if channel == "" {
+		return nil, newValidationError("channel", "%s needs no confirmation", channelPush)
+	}
+
+	buff := make([]byte, 8)
+
+	if _, err := rand.Read(buff); err != nil {
+		return nil, err
+	}
+
+	token := &ChannelToken{
+		UserID:     userid,
+		Channel:    channel,
+		Target:     target,
+		Token:      hex.EncodeToString(buff),
+		ExpireTime: time.Now().Add(service.channelTokenExpire),
+	}
+
+	_, err := service.db.Exec(
+		`INSERT INTO neo_channel_token (user_i_d, channel, target, token, expire_time) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_i_d, channel, target) DO UPDATE SET token = excluded.token, expire_time = excluded.expire_time`,
+		token.UserID, token.Channel, token.Target, token.Token, formatDBTime(token.ExpireTime, service.db.DatabaseTZ),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.notifiers[channel].notify(&Notification{
+		UserID:  userid,
+		Channel: channel,
+		Target:  target,
+		Message: fmt.Sprintf("Confirmation token %s, valid until %s", token.Token, token.ExpireTime.Format(time.RFC3339)),
+	})
+
+	if err != nil {
+		return nil, newValidationError("target", "send confirmation token to %s error, %s", target, err)
+	}
+
+	return token, nil
+}
+
+// confirmTarget check the token confirms the target of channel for userid before it is stored,
+// push and the targets already stored for the user need no token, the token is single use
+func (service *HTTPServer) confirmTarget(userid string, channel string, target string, token string) error {
+	if channel == "" {
+		return nil
+	}
+
+	stored, err := service.db.
+		Where("user_i_d = ? and channel = ? and target = ?", userid, channel, target).
+		Exist(new(WalletSettings))
+
+	if err != nil || stored {
+		return err
+	}
+
+	if token == "" {
+		return newValidationError("token", "confirmation token of %s required", target)
+	}
+
+	deleted, err := service.db.
+		Where("user_i_d = ? and channel = ? and target = ? and token = ? and expire_time > ?",
+			userid, channel, target, token, formatDBTime(time.Now(), service.db.DatabaseTZ)).
+		Delete(new(ChannelToken))
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return newValidationError("token", "confirmation token not found or expired")
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/dynamicgo/config"
+	"github.com/dynamicgo/slf4go"
+	orderservice "github.com/inwecrypto/neo-order-service"
+	_ "github.com/lib/pq"
+)
+
+var logger = slf4go.Get("neo-order-stats")
+var configpath = flag.String("conf", "./neo-order-service.json", "neo order service config file")
+
+// one-off rebuild of the address stats of the existing wallets and subscriptions,
+// safe to rerun, run it once after upgrading to a release maintaining the stats.
+// Exits with status 1 on failure so deploy scripts can stop the upgrade.
+func main() {
+	flag.Parse()
+
+	neocnf, err := config.NewFromFile(*configpath)
+
+	if err != nil {
+		logger.ErrorF("load neo config err , %s", err)
+		os.Exit(1)
+	}
+
+	if err := orderservice.RebuildAllStats(neocnf); err != nil {
+		logger.ErrorF("rebuild stats err , %s", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,223 @@
+package orderservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/dynamicgo/config"
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+)
+
+// Contact label a user attached to a counterparty address
+type Contact struct {
+	ID         int64     `json:"-" xorm:"pk autoincr"`
+	UserID     string    `json:"userid" xorm:"notnull unique(user_contact)"`
+	Address    string    `json:"address" xorm:"notnull unique(user_contact)"`
+	Label      string    `json:"label" xorm:"notnull"`
+	CreateTime time.Time `json:"-" xorm:"TIMESTAMP notnull created"`
+	UpdateTime time.Time `json:"-" xorm:"TIMESTAMP notnull updated"`
+}
+
+// TableName xorm table name
+func (table *Contact) TableName() string {
+	return "neo_contact"
+}
+
+// knownAddress label of a well known address, e.g. an exchange hot wallet
+type knownAddress struct {
+	Address string `json:"address"`
+	Label   string `json:"label"`
+}
+
+// labelBook resolve the labels of addresses for a user, the user contacts first, then
+// the labels of the user wallets and subscriptions, then the well known addresses
+type labelBook struct {
+	db    *xorm.Engine
+	known map[string]string
+}
+
+// newLabelBook create the label book with the well known addresses of the order.contacts.known
+// JSON file, a list of {"address": ..., "label": ...}
+func newLabelBook(cnf *config.Config, db *xorm.Engine) (*labelBook, error) {
+	book := &labelBook{
+		db:    db,
+		known: make(map[string]string),
+	}
+
+	path := cnf.GetString("order.contacts.known", "")
+
+	if path == "" {
+		return book, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []*knownAddress
+
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		return nil, fmt.Errorf("known addresses file %s error, %s", path, err)
+	}
+
+	for _, known := range addresses {
+		if _, err := validateAddress("address", known.Address); err != nil {
+			return nil, fmt.Errorf("known address %s error, %s", known.Address, err)
+		}
+
+		if err := validateLabel(known.Label); err != nil {
+			return nil, fmt.Errorf("known address %s error, %s", known.Address, err)
+		}
+
+		book.known[known.Address] = known.Label
+	}
+
+	return book, nil
+}
+
+func validateLabel(label string) error {
+	if label == "" || len(label) > 64 {
+		return newValidationError("label", "label must be 1 to 64 chars")
+	}
+
+	return nil
+}
+
+// labels the labels of addresses seen by userid, userid may be empty for the well known labels only
+func (book *labelBook) labels(userid string, addresses []string) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	for _, address := range addresses {
+		if label, ok := book.known[address]; ok {
+			labels[address] = label
+		}
+	}
+
+	if userid == "" || len(addresses) == 0 {
+		return labels, nil
+	}
+
+	var settings []*WalletSettings
+
+	if err := book.db.Where("user_i_d = ? and label <> ''", userid).In("address", addresses).Find(&settings); err != nil {
+		return nil, err
+	}
+
+	for _, setting := range settings {
+		labels[setting.Address] = setting.Label
+	}
+
+	var subscriptions []*Subscription
+
+	err := book.db.Where("user_i_d = ? and kind = ? and label <> ''", userid, subscribeAddress).In("target", addresses).Find(&subscriptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		labels[subscription.Target] = subscription.Label
+	}
+
+	var contacts []*Contact
+
+	if err := book.db.Where("user_i_d = ?", userid).In("address", addresses).Find(&contacts); err != nil {
+		return nil, err
+	}
+
+	for _, contact := range contacts {
+		labels[contact.Address] = contact.Label
+	}
+
+	return labels, nil
+}
+
+// saveContact insert or update the contact of userid, returns true if it was created
+func (service *HTTPServer) saveContact(contact *Contact) (bool, error) {
+	updated, err := service.db.
+		Where(`user_i_d = ? and "address" = ?`, contact.UserID, contact.Address).
+		Cols("label", "update_time").
+		Update(contact)
+
+	if err != nil || updated != 0 {
+		return false, err
+	}
+
+	if _, err := service.db.Insert(contact); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// listContacts the contacts of userid ordered by label
+func (service *HTTPServer) listContacts(userid string) ([]*Contact, error) {
+	contacts := make([]*Contact, 0)
+
+	if err := service.db.Where("user_i_d = ?", userid).Asc("label", "id").Find(&contacts); err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+func (service *HTTPServer) deleteContact(userid string, address string) error {
+	deleted, err := service.db.Where(`user_i_d = ? and "address" = ?`, userid, address).Delete(new(Contact))
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errNotFound("contact %s of user %s not found", address, userid)
+	}
+
+	return nil
+}
+
+// viewer the user whose labels are shown in the orders of the request, the userid query
+// parameter if any, else the authenticated caller
+func (service *HTTPServer) viewer(ctx *gin.Context) (string, error) {
+	if userid := ctx.Query("userid"); userid != "" {
+		return userid, service.authorizeUser(ctx, userid)
+	}
+
+	if caller := service.principal(ctx); caller != nil {
+		return caller.UserID, nil
+	}
+
+	return "", nil
+}
+
+// labelOrders set the from and to labels of orders seen by the viewer of the request
+func (service *HTTPServer) labelOrders(ctx *gin.Context, orders []*Order) error {
+	userid, err := service.viewer(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	addresses := make([]string, 0, len(orders)*2)
+
+	for _, order := range orders {
+		addresses = append(addresses, order.From, order.To)
+	}
+
+	labels, err := service.labels.labels(userid, addresses)
+
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		order.FromLabel = labels[order.From]
+		order.ToLabel = labels[order.To]
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package orderservice
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-xorm/xorm"
+)
+
+// contextFilterPrefix query parameter prefix of the order context filters, e.g. context.merchantId=xxx
+const contextFilterPrefix = "context."
+
+var contextKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// normalizeContext validate the order context and returns the compact JSON stored in the
+// JSONB column. The context must be a JSON object, a legacy string context is wrapped as
+// {"memo": "..."} the same way the JSONB migration converts the existing TEXT values.
+func (service *HTTPServer) normalizeContext(context json.RawMessage) (*string, error) {
+	if len(context) == 0 || string(context) == "null" {
+		return nil, nil
+	}
+
+	if len(context) > service.maxContextSize {
+		return nil, newValidationError("context", "context must be at most %d bytes", service.maxContextSize)
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(context, &value); err != nil {
+		return nil, newValidationError("context", "context must be JSON object")
+	}
+
+	if memo, ok := value.(string); ok {
+		value = map[string]interface{}{"memo": memo}
+	}
+
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, newValidationError("context", "context must be JSON object")
+	}
+
+	if service.contextSchema != nil {
+		if err := service.contextSchema.validate("context", value); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := string(data)
+
+	return &result, nil
+}
+
+// parseContextFilters parse the context.<key>[.<key>...]=value query parameters into
+// JSON path to value filters, nested keys are separated by dots
+func parseContextFilters(values map[string][]string) (map[string]string, error) {
+	filters := make(map[string]string)
+
+	for name, value := range values {
+		if !strings.HasPrefix(name, contextFilterPrefix) {
+			continue
+		}
+
+		keys := strings.Split(strings.TrimPrefix(name, contextFilterPrefix), ".")
+
+		for _, key := range keys {
+			if !contextKeyPattern.MatchString(key) {
+				return nil, newValidationError(name, "context key must be 1-64 letters, digits, _ or -")
+			}
+		}
+
+		filters["{"+strings.Join(keys, ",")+"}"] = value[0]
+	}
+
+	return filters, nil
+}
+
+// filterContext apply the context filters to session, the values are matched as text
+func filterContext(session *xorm.Session, filters map[string]string) *xorm.Session {
+	paths := make([]string, 0, len(filters))
+
+	for path := range filters {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		session = session.And(`"context" #>> ?::text[] = ?`, path, filters[path])
+	}
+
+	return session
+}
@@ -0,0 +1,145 @@
+package orderservice
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// api error codes
+const (
+	errCodeValidation   = "invalid_parameter"
+	errCodeUnauthorized = "unauthorized"
+	errCodeForbidden    = "forbidden"
+	errCodeNotFound     = "not_found"
+	errCodeConflict     = "conflict"
+	errCodeRateLimited  = "rate_limited"
+	errCodeInternal     = "internal_error"
+	errCodeUnavailable  = "unavailable"
+)
+
+// pqUniqueViolation postgres unique_violation error code
+const pqUniqueViolation = "23505"
+
+const requestIDHeader = "X-Request-ID"
+
+const requestIDKey = "requestId"
+
+// clientRequestID the client provided request ids accepted as is, anything else is
+// replaced so it can not inject into the logs or bloat the response headers
+var clientRequestID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// apiError typed api error with http status and stable error code
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (err *apiError) Error() string {
+	return err.Message
+}
+
+func newAPIError(status int, code string, format string, args ...interface{}) *apiError {
+	return &apiError{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func errNotFound(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusNotFound, errCodeNotFound, format, args...)
+}
+
+func errConflict(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusConflict, errCodeConflict, format, args...)
+}
+
+func errUnauthorized(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusUnauthorized, errCodeUnauthorized, format, args...)
+}
+
+func errForbidden(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusForbidden, errCodeForbidden, format, args...)
+}
+
+func errTooManyRequests(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusTooManyRequests, errCodeRateLimited, format, args...)
+}
+
+func errUnavailable(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusServiceUnavailable, errCodeUnavailable, format, args...)
+}
+
+// errorResponse api error response body
+type errorResponse struct {
+	Code      string `json:"code"`
+	Error     string `json:"error"`
+	Field     string `json:"field,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// requestID middleware bind a request id to the request, reuse the client provided one if valid
+func requestID(ctx *gin.Context) {
+	id := ctx.GetHeader(requestIDHeader)
+
+	if !clientRequestID.MatchString(id) {
+		buff := make([]byte, 16)
+
+		if _, err := rand.Read(buff); err == nil {
+			id = hex.EncodeToString(buff)
+		}
+	}
+
+	ctx.Set(requestIDKey, id)
+	ctx.Header(requestIDHeader, id)
+
+	ctx.Next()
+}
+
+// abort map err to the api error response and abort the request
+func (service *HTTPServer) abort(ctx *gin.Context, err error) {
+	response := &errorResponse{
+		Code:      errCodeInternal,
+		Error:     err.Error(),
+		RequestID: ctx.GetString(requestIDKey),
+	}
+
+	status := http.StatusInternalServerError
+
+	switch e := err.(type) {
+	case *validationError:
+		status = http.StatusBadRequest
+		response.Code = errCodeValidation
+		response.Error = e.Message
+		response.Field = e.Field
+	case *apiError:
+		status = e.Status
+		response.Code = e.Code
+
+		if status == http.StatusUnauthorized {
+			ctx.Header("WWW-Authenticate", "Bearer")
+		}
+	case *pq.Error:
+		if e.Code == pqUniqueViolation {
+			status = http.StatusConflict
+			response.Code = errCodeConflict
+			response.Error = "resource already exists"
+		}
+	}
+
+	if status == http.StatusInternalServerError {
+		service.ErrorF("[%s] %s %s error :%s", response.RequestID, ctx.Request.Method, ctx.Request.URL.Path, err)
+		response.Error = "internal server error"
+	} else {
+		service.DebugF("[%s] %s %s error :%s", response.RequestID, ctx.Request.Method, ctx.Request.URL.Path, err)
+	}
+
+	ctx.AbortWithStatusJSON(status, response)
+}
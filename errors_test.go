@@ -0,0 +1,48 @@
+package orderservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	engine := gin.New()
+	engine.Use(requestID)
+	engine.GET("/", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ctx.GetString(requestIDKey))
+	})
+
+	for _, test := range []struct {
+		header string
+		reused bool
+	}{
+		{"", false},
+		{"req-1_A", true},
+		{strings.Repeat("a", 64), true},
+		{strings.Repeat("a", 65), false},
+		{"id\r\nX-Injected: 1", false},
+		{"id with spaces", false},
+		{"../etc", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIDHeader, test.header)
+
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+
+		id := recorder.Header().Get(requestIDHeader)
+
+		assert.Equal(t, id, recorder.Body.String())
+
+		if test.reused {
+			assert.Equal(t, test.header, id)
+		} else {
+			assert.Regexp(t, "^[0-9a-f]{32}$", id, test.header)
+		}
+	}
+}
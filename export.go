@@ -0,0 +1,350 @@
+package orderservice
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/inwecrypto/neodb"
+)
+
+// export formats
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+	exportOFX   = "ofx"
+)
+
+// exportColumns the stable column layout of the csv export, jsonl uses the same names
+var exportColumns = []string{
+	"tx", "block", "createTime", "confirmTime", "status", "direction",
+	"from", "to", "asset", "symbol", "value", "fee",
+}
+
+// exportBatch orders loaded per query while streaming the export
+const exportBatch = 500
+
+// ExportRow one exported order
+type ExportRow struct {
+	Tx          string `json:"tx"`
+	Block       int64  `json:"block"`
+	CreateTime  string `json:"createTime"`
+	ConfirmTime string `json:"confirmTime"`
+	Status      string `json:"status"`
+	Direction   string `json:"direction"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Asset       string `json:"asset"`
+	Symbol      string `json:"symbol"`
+	Value       string `json:"value"`
+	Fee         string `json:"fee"`
+
+	id         int64
+	createTime time.Time
+}
+
+func (row *ExportRow) columns() []string {
+	return []string{
+		row.Tx, strconv.FormatInt(row.Block, 10), row.CreateTime, row.ConfirmTime, row.Status, row.Direction,
+		row.From, row.To, row.Asset, row.Symbol, row.Value, row.Fee,
+	}
+}
+
+// exportQuery filters of the export api
+type exportQuery struct {
+	Address   string
+	Format    string
+	Confirmed bool
+	From      *time.Time
+	To        *time.Time
+}
+
+func (service *HTTPServer) parseExportQuery(ctx *gin.Context) (*exportQuery, error) {
+	query := &exportQuery{
+		Format: ctx.DefaultQuery("format", exportCSV),
+	}
+
+	var err error
+
+	if query.Address, err = validateAddress("address", ctx.Param("address")); err != nil {
+		return nil, err
+	}
+
+	switch query.Format {
+	case exportCSV, exportJSONL, exportOFX:
+	default:
+		return nil, newValidationError("format", "format must be %s, %s or %s", exportCSV, exportJSONL, exportOFX)
+	}
+
+	switch status := ctx.DefaultQuery("status", orderStatusConfirmed); status {
+	case orderStatusConfirmed:
+		query.Confirmed = true
+	case "all":
+	default:
+		return nil, newValidationError("status", "status must be %s or all", orderStatusConfirmed)
+	}
+
+	if query.From, err = parseTimeQuery(ctx, "from"); err != nil {
+		return nil, err
+	}
+
+	if query.To, err = parseTimeQuery(ctx, "to"); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+// exportWriter write the export rows in one format
+type exportWriter interface {
+	header() error
+	write(row *ExportRow) error
+	footer() error
+	flush() error
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (writer *csvExportWriter) header() error {
+	return writer.writer.Write(exportColumns)
+}
+
+func (writer *csvExportWriter) write(row *ExportRow) error {
+	return writer.writer.Write(row.columns())
+}
+
+func (writer *csvExportWriter) footer() error {
+	return writer.flush()
+}
+
+func (writer *csvExportWriter) flush() error {
+	writer.writer.Flush()
+
+	return writer.writer.Error()
+}
+
+type jsonlExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (writer *jsonlExportWriter) header() error {
+	return nil
+}
+
+func (writer *jsonlExportWriter) write(row *ExportRow) error {
+	return writer.encoder.Encode(row)
+}
+
+func (writer *jsonlExportWriter) footer() error {
+	return nil
+}
+
+func (writer *jsonlExportWriter) flush() error {
+	return nil
+}
+
+// ofxExportWriter OFX 2 bank statement, one STMTTRN per order signed by direction,
+// the asset symbol is carried by the memo as assets are no ISO currencies
+type ofxExportWriter struct {
+	writer io.Writer
+	query  *exportQuery
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+func (writer *ofxExportWriter) header() error {
+	start := time.Unix(0, 0)
+	end := time.Now()
+
+	if writer.query.From != nil {
+		start = *writer.query.From
+	}
+
+	if writer.query.To != nil {
+		end = *writer.query.To
+	}
+
+	_, err := fmt.Fprintf(writer.writer, `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS><CURDEF>XXX</CURDEF>
+<BANKACCTFROM><BANKID>NEO</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>
+`, ofxTime(time.Now()), html.EscapeString(writer.query.Address), ofxTime(start), ofxTime(end))
+
+	return err
+}
+
+func (writer *ofxExportWriter) write(row *ExportRow) error {
+	trnType := "CREDIT"
+	amount := row.Value
+	name := row.From
+
+	switch row.Direction {
+	case txOutgoing:
+		trnType = "DEBIT"
+		amount = "-" + row.Value
+		name = row.To
+	case txSelf:
+		trnType = "XFER"
+	}
+
+	posted := row.createTime
+
+	if confirmTime, err := time.Parse(time.RFC3339Nano, row.ConfirmTime); err == nil {
+		posted = confirmTime
+	}
+
+	_, err := fmt.Fprintf(writer.writer,
+		"<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s-%d</FITID><NAME>%s</NAME><MEMO>%s %s</MEMO></STMTTRN>\n",
+		trnType, ofxTime(posted), amount, row.Tx, row.id, html.EscapeString(name), html.EscapeString(row.Symbol), row.Tx,
+	)
+
+	return err
+}
+
+func (writer *ofxExportWriter) footer() error {
+	_, err := io.WriteString(writer.writer, "</BANKTRANLIST>\n</STMTRS></STMTTRNRS></BANKMSGSRSV1>\n</OFX>\n")
+
+	return err
+}
+
+func (writer *ofxExportWriter) flush() error {
+	return nil
+}
+
+func (service *HTTPServer) newExportRow(address string, torder *neodb.Order, fees map[string]string) *ExportRow {
+	row := &ExportRow{
+		Tx:         torder.TX,
+		Block:      torder.Block,
+		CreateTime: torder.CreateTime.Format(time.RFC3339Nano),
+		Status:     orderStatusPending,
+		Direction:  txIncoming,
+		From:       torder.From,
+		To:         torder.To,
+		Asset:      torder.Asset,
+		Symbol:     service.assets.symbol(torder.Asset),
+		Value:      service.assets.formatValue(torder.Asset, torder.Value),
+		id:         torder.ID,
+		createTime: torder.CreateTime,
+	}
+
+	if torder.ConfirmTime != nil {
+		row.ConfirmTime = torder.ConfirmTime.Format(time.RFC3339Nano)
+		row.Status = orderStatusConfirmed
+	}
+
+	switch {
+	case torder.From == address && torder.To == address:
+		row.Direction = txSelf
+	case torder.From == address:
+		row.Direction = txOutgoing
+	}
+
+	// the sender pays the fee, the recipient rows leave it empty
+	if torder.From == address {
+		row.Fee = fees[torder.TX]
+	}
+
+	return row
+}
+
+// exportOrders stream the orders of the query oldest first, loading exportBatch orders at a time.
+// The status is sent before the first row, so an error while streaming truncates the export
+// and is only logged.
+func (service *HTTPServer) exportOrders(ctx *gin.Context, query *exportQuery) {
+	var writer exportWriter
+
+	switch query.Format {
+	case exportCSV:
+		ctx.Header("Content-Type", "text/csv; charset=utf-8")
+		writer = &csvExportWriter{writer: csv.NewWriter(ctx.Writer)}
+	case exportJSONL:
+		ctx.Header("Content-Type", "application/x-ndjson")
+		writer = &jsonlExportWriter{encoder: json.NewEncoder(ctx.Writer)}
+	case exportOFX:
+		ctx.Header("Content-Type", "application/x-ofx")
+		writer = &ofxExportWriter{writer: ctx.Writer, query: query}
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-%s.%s"`, query.Address, query.Format))
+	ctx.Status(http.StatusOK)
+
+	if err := service.streamExport(ctx, query, writer); err != nil {
+		service.ErrorF("[%s] export orders of %s error :%s", ctx.GetString(requestIDKey), query.Address, err)
+	}
+}
+
+func (service *HTTPServer) streamExport(ctx *gin.Context, query *exportQuery, writer exportWriter) error {
+	if err := writer.header(); err != nil {
+		return err
+	}
+
+	var cursor *orderCursor
+
+	for {
+		session := service.db.Where(`("from" = ? or "to" = ?)`, query.Address, query.Address)
+
+		if query.Confirmed {
+			session = session.And("confirm_time is not null")
+		}
+
+		if query.From != nil {
+			session = session.And("create_time >= ?", formatDBTime(*query.From, service.db.DatabaseTZ))
+		}
+
+		if query.To != nil {
+			session = session.And("create_time < ?", formatDBTime(*query.To, service.db.DatabaseTZ))
+		}
+
+		if cursor != nil {
+			createTime := formatDBTime(cursor.CreateTime, service.db.DatabaseTZ)
+
+			session = session.And("(create_time > ? or (create_time = ? and id > ?))", createTime, createTime, cursor.ID)
+		}
+
+		torders := make([]*neodb.Order, 0, exportBatch)
+
+		if err := session.Asc("create_time", "id").Limit(exportBatch).Find(&torders); err != nil {
+			return err
+		}
+
+		fees, err := service.txFees(torders)
+
+		if err != nil {
+			return err
+		}
+
+		for _, torder := range torders {
+			if err := writer.write(service.newExportRow(query.Address, torder, fees)); err != nil {
+				return err
+			}
+		}
+
+		if len(torders) < exportBatch {
+			return writer.footer()
+		}
+
+		last := torders[len(torders)-1]
+
+		cursor = &orderCursor{CreateTime: last.CreateTime, ID: last.ID}
+
+		if err := writer.flush(); err != nil {
+			return err
+		}
+
+		ctx.Writer.Flush()
+	}
+}
@@ -0,0 +1,419 @@
+package orderservice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/inwecrypto/neodb"
+)
+
+// TxFee GAS fee paid by a confirmed tx, cached once computed from neo_utxo
+type TxFee struct {
+	ID         int64     `xorm:"pk autoincr"`
+	TX         string    `xorm:"notnull unique"`
+	From       string    `xorm:"notnull index"` // the address paying the fee
+	Block      int64     `xorm:"notnull"`
+	Fee        string    `xorm:"notnull"`
+	CreateTime time.Time `xorm:"TIMESTAMP notnull created"`
+}
+
+// TableName xorm table name
+func (table *TxFee) TableName() string {
+	return "neo_tx_fee"
+}
+
+// FeeSummary GAS fees paid by an address
+type FeeSummary struct {
+	Address string `json:"address"`
+	Asset   string `json:"asset"`
+	Fee     string `json:"fee"`
+	Txs     int    `json:"txs"`
+	Unknown int    `json:"unknown"` // txs whose fee can not be attributed
+}
+
+// feeBatch the txs whose fees are loaded from the cache or computed together
+const feeBatch = 500
+
+// computeFees compute the GAS fees of txs as GAS inputs minus GAS outputs, in a fixed number
+// of queries. neo_utxo does not record the spending tx, so the inputs of a tx are the utxos
+// spent in its block by its sender, and a fee is only computed when it can be attributed:
+// the tx has a single sender, the sender sent no other tx in the block, and the spent inputs
+// of the sender are indexed already. The other txs are missing from the result.
+func (service *HTTPServer) computeFees(txs []string) ([]*TxFee, error) {
+	var transfers []*neodb.Tx
+
+	if err := service.db.In("t_x", txs).Cols("t_x", "from", "block").Find(&transfers); err != nil {
+		return nil, err
+	}
+
+	senders := make(map[string]map[string]bool)
+	blocks := make(map[string]int64)
+
+	for _, transfer := range transfers {
+		if senders[transfer.TX] == nil {
+			senders[transfer.TX] = make(map[string]bool)
+		}
+
+		senders[transfer.TX][transfer.From] = true
+		blocks[transfer.TX] = int64(transfer.Block)
+	}
+
+	fees := make(map[string]*TxFee)
+	var addresses []string
+	var heights []int64
+
+	for tx, from := range senders {
+		if len(from) != 1 {
+			continue
+		}
+
+		for address := range from {
+			if address == "" {
+				continue
+			}
+
+			fees[tx] = &TxFee{TX: tx, From: address, Block: blocks[tx]}
+			addresses = append(addresses, address)
+			heights = append(heights, blocks[tx])
+		}
+	}
+
+	if len(fees) == 0 {
+		return nil, nil
+	}
+
+	var sent []*neodb.Tx
+
+	if err := service.db.In(`"from"`, addresses).In("block", heights).Cols("t_x", "from", "block").Find(&sent); err != nil {
+		return nil, err
+	}
+
+	// the txs sent by each address in each block
+	sentTxs := make(map[string]map[string]bool)
+
+	for _, transfer := range sent {
+		key := fmt.Sprintf("%s|%d", transfer.From, transfer.Block)
+
+		if sentTxs[key] == nil {
+			sentTxs[key] = make(map[string]bool)
+		}
+
+		sentTxs[key][transfer.TX] = true
+	}
+
+	var spent []*neodb.UTXO
+
+	if err := service.db.In(`"address"`, addresses).In("spent_block", heights).Find(&spent); err != nil {
+		return nil, err
+	}
+
+	inputs := make(map[string]Amount)
+
+	for _, utxo := range spent {
+		key := fmt.Sprintf("%s|%d", utxo.Address, utxo.SpentBlock)
+
+		sum, ok := inputs[key]
+
+		if !ok {
+			sum = Amount{Decimals: service.assets.decimals(gasAsset)}
+		}
+
+		if utxo.Asset == gasAsset {
+			value, err := service.assets.parseValue(gasAsset, utxo.Value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if sum, err = sum.Add(value); err != nil {
+				return nil, err
+			}
+		}
+
+		inputs[key] = sum
+	}
+
+	outputs, err := service.sumOutputs(fees)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*TxFee, 0, len(fees))
+
+	for tx, fee := range fees {
+		key := fmt.Sprintf("%s|%d", fee.From, fee.Block)
+
+		// a tx spends at least one input, none found means neo_utxo is not indexed yet
+		in, indexed := inputs[key]
+
+		if !indexed || len(sentTxs[key]) != 1 {
+			continue
+		}
+
+		out := outputs[tx]
+
+		if in.Cmp(out) < 0 {
+			continue
+		}
+
+		value, err := in.Sub(out)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fee.Fee = value.String()
+		result = append(result, fee)
+	}
+
+	return result, nil
+}
+
+// sumOutputs sum the GAS outputs of the txs of fees
+func (service *HTTPServer) sumOutputs(fees map[string]*TxFee) (map[string]Amount, error) {
+	txs := make([]string, 0, len(fees))
+
+	for tx := range fees {
+		txs = append(txs, tx)
+	}
+
+	var utxos []*neodb.UTXO
+
+	if err := service.db.Where("asset = ?", gasAsset).In("t_x", txs).Find(&utxos); err != nil {
+		return nil, err
+	}
+
+	outputs := make(map[string]Amount)
+
+	for _, tx := range txs {
+		outputs[tx] = Amount{Decimals: service.assets.decimals(gasAsset)}
+	}
+
+	for _, utxo := range utxos {
+		value, err := service.assets.parseValue(gasAsset, utxo.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if outputs[utxo.TX], err = outputs[utxo.TX].Add(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return outputs, nil
+}
+
+// cacheFees save the computed fees in one statement, the fees cached concurrently are kept
+func (service *HTTPServer) cacheFees(fees []*TxFee) error {
+	if len(fees) == 0 {
+		return nil
+	}
+
+	now := formatDBTime(time.Now(), service.db.DatabaseTZ)
+
+	values := make([]string, 0, len(fees))
+	args := make([]interface{}, 0, 5*len(fees))
+
+	for _, fee := range fees {
+		values = append(values, "(?, ?, ?, ?, ?)")
+		args = append(args, fee.TX, fee.From, fee.Block, fee.Fee, now)
+	}
+
+	_, err := service.db.Exec(
+		`INSERT INTO neo_tx_fee (t_x, "from", block, fee, create_time) VALUES `+
+			strings.Join(values, ", ")+` ON CONFLICT (t_x) DO NOTHING`,
+		args...,
+	)
+
+	return err
+}
+
+// loadFees get the fees of txs from the cache, the missing ones are computed and only the
+// attributable ones, whose inputs are indexed, are cached; the others are missing from the result
+func (service *HTTPServer) loadFees(txs []string) (map[string]*TxFee, error) {
+	fees := make(map[string]*TxFee)
+
+	if len(txs) == 0 {
+		return fees, nil
+	}
+
+	var cached []*TxFee
+
+	if err := service.db.In("t_x", txs).Find(&cached); err != nil {
+		return nil, err
+	}
+
+	for _, fee := range cached {
+		fees[fee.TX] = fee
+	}
+
+	var missing []string
+
+	for _, tx := range txs {
+		if _, ok := fees[tx]; !ok {
+			missing = append(missing, tx)
+		}
+	}
+
+	if len(missing) == 0 {
+		return fees, nil
+	}
+
+	computed, err := service.computeFees(missing)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.cacheFees(computed); err != nil {
+		return nil, err
+	}
+
+	for _, fee := range computed {
+		fees[fee.TX] = fee
+	}
+
+	return fees, nil
+}
+
+// txFees get the fees of the confirmed orders keyed by tx
+func (service *HTTPServer) txFees(torders []*neodb.Order) (map[string]string, error) {
+	seen := make(map[string]bool)
+	var txs []string
+
+	for _, torder := range torders {
+		if torder.ConfirmTime != nil && torder.Block >= 0 && !seen[torder.TX] {
+			seen[torder.TX] = true
+			txs = append(txs, torder.TX)
+		}
+	}
+
+	fees := make(map[string]string)
+
+	for start := 0; start < len(txs); start += feeBatch {
+		end := start + feeBatch
+
+		if end > len(txs) {
+			end = len(txs)
+		}
+
+		batch, err := service.loadFees(txs[start:end])
+
+		if err != nil {
+			return nil, err
+		}
+
+		for tx, fee := range batch {
+			fees[tx] = fee.Fee
+		}
+	}
+
+	return fees, nil
+}
+
+// newOrders convert the orders to the api objects with the fees of the confirmed ones and their fiat values
+func (service *HTTPServer) newOrders(torders []*neodb.Order) ([]*Order, error) {
+	fees, err := service.txFees(torders)
+
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*Order, 0, len(torders))
+
+	for _, torder := range torders {
+		order := service.newOrder(torder)
+
+		order.Fee = fees[torder.TX]
+
+		orders = append(orders, order)
+	}
+
+	if err := service.orderFiat(orders, torders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// getFeeSummary sum the fees paid by address for the confirmed txs it sent, created in [since, until),
+// the orders are scanned in pages of feeBatch and each tx is counted once
+func (service *HTTPServer) getFeeSummary(address string, since *time.Time, until *time.Time) (*FeeSummary, error) {
+	summary := &FeeSummary{
+		Address: address,
+		Asset:   gasAsset,
+	}
+
+	sum := Amount{Decimals: service.assets.decimals(gasAsset)}
+	seen := make(map[string]bool)
+
+	var after int64
+
+	for {
+		session := service.db.Where(`"from" = ? and confirm_time is not null and block >= 0 and id > ?`, address, after)
+
+		if since != nil {
+			session = session.And("create_time >= ?", formatDBTime(*since, service.db.DatabaseTZ))
+		}
+
+		if until != nil {
+			session = session.And("create_time < ?", formatDBTime(*until, service.db.DatabaseTZ))
+		}
+
+		var torders []*neodb.Order
+
+		if err := session.Cols("id", "t_x").Asc("id").Limit(feeBatch).Find(&torders); err != nil {
+			return nil, err
+		}
+
+		if len(torders) == 0 {
+			break
+		}
+
+		var txs []string
+
+		for _, torder := range torders {
+			after = torder.ID
+
+			if !seen[torder.TX] {
+				seen[torder.TX] = true
+				txs = append(txs, torder.TX)
+			}
+		}
+
+		fees, err := service.loadFees(txs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			fee, ok := fees[tx]
+
+			// the fee of a tx is paid by its single sender, which may not be address
+			if !ok || fee.From != address {
+				summary.Unknown++
+				continue
+			}
+
+			value, err := service.assets.parseValue(gasAsset, fee.Fee)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if sum, err = sum.Add(value); err != nil {
+				return nil, err
+			}
+
+			summary.Txs++
+		}
+	}
+
+	summary.Fee = sum.String()
+
+	return summary, nil
+}
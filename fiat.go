@@ -0,0 +1,266 @@
+package orderservice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dynamicgo/config"
+	"github.com/dynamicgo/slf4go"
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neo-order-service/price"
+	"github.com/inwecrypto/neodb"
+)
+
+// PriceHistory price of one asset in a fiat currency quoted by the price source
+type PriceHistory struct {
+	ID        int64     `xorm:"pk autoincr"`
+	Asset     string    `xorm:"notnull index(price_asset)"`
+	Currency  string    `xorm:"notnull index(price_asset)"`
+	Price     string    `xorm:"NUMERIC notnull"`
+	QuoteTime time.Time `xorm:"TIMESTAMP notnull index(price_asset)"`
+}
+
+// TableName xorm table name
+func (table *PriceHistory) TableName() string {
+	return "neo_price"
+}
+
+// OrderFiat fiat valuation of an order
+type OrderFiat struct {
+	Currency     string `json:"currency"`
+	Price        string `json:"price,omitempty"` // at confirm time, confirmed orders only
+	Value        string `json:"value,omitempty"`
+	CurrentPrice string `json:"currentPrice,omitempty"`
+	CurrentValue string `json:"currentValue,omitempty"`
+}
+
+// priceRecorder record the prices of the known assets into neo_price, run by the tx watcher
+// process only; the http servers use it to read the recorded prices
+type priceRecorder struct {
+	slf4go.Logger
+	db        *xorm.Engine
+	assets    *assetRegistry
+	source    price.Source
+	currency  string
+	decimals  int
+	interval  time.Duration
+	maxAge    time.Duration
+	retention time.Duration // prices older are deleted, kept forever if zero
+}
+
+// newPriceRecorder create the recorder of the configured price source, nil if none is configured
+func newPriceRecorder(cnf *config.Config, db *xorm.Engine, assets *assetRegistry) (*priceRecorder, error) {
+	var source price.Source
+
+	switch kind := cnf.GetString("order.price.source", ""); kind {
+	case "":
+		return nil, nil
+	case "file":
+		source = price.NewFileSource(cnf.GetString("order.price.file", "./prices.json"))
+	case "http":
+		endpoint := cnf.GetString("order.price.url", "")
+
+		if endpoint == "" {
+			return nil, fmt.Errorf("order.price.url is required by the http price source")
+		}
+
+		source = price.NewHTTPSource(endpoint, cnf.GetDuration("order.price.timeout", 10*time.Second))
+	default:
+		return nil, fmt.Errorf("unknown price source %s", kind)
+	}
+
+	return &priceRecorder{
+		Logger:    slf4go.Get("price-recorder"),
+		db:        db,
+		assets:    assets,
+		source:    source,
+		currency:  cnf.GetString("order.price.currency", "USD"),
+		decimals:  int(cnf.GetInt64("order.price.decimals", 2)),
+		interval:  cnf.GetDuration("order.price.interval", 5*time.Minute),
+		maxAge:    cnf.GetDuration("order.price.maxage", time.Hour),
+		retention: cnf.GetDuration("order.price.retention", 365*24*time.Hour),
+	}, nil
+}
+
+func (recorder *priceRecorder) run() {
+	ticker := time.NewTicker(recorder.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := recorder.record(); err != nil {
+			recorder.ErrorF("record %s prices error, %s", recorder.currency, err)
+		}
+
+		if err := recorder.purge(); err != nil {
+			recorder.ErrorF("purge %s prices error, %s", recorder.currency, err)
+		}
+
+		<-ticker.C
+	}
+}
+
+// record quote the current prices of all the known assets
+func (recorder *priceRecorder) record() error {
+	assets, err := recorder.assets.list()
+
+	if err != nil {
+		return err
+	}
+
+	symbols := make([]string, 0, len(assets))
+
+	for _, asset := range assets {
+		symbols = append(symbols, asset.Symbol)
+	}
+
+	prices, err := recorder.source.Prices(recorder.currency, symbols)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rows := make([]*PriceHistory, 0, len(prices))
+
+	for _, asset := range assets {
+		if value, ok := prices[asset.Symbol]; ok {
+			rows = append(rows, &PriceHistory{
+				Asset:     asset.Asset,
+				Currency:  recorder.currency,
+				Price:     value,
+				QuoteTime: now,
+			})
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err = recorder.db.Insert(&rows)
+
+	return err
+}
+
+// purge delete the prices older than the retention window
+func (recorder *priceRecorder) purge() error {
+	if recorder.retention <= 0 {
+		return nil
+	}
+
+	_, err := recorder.db.Exec(
+		"DELETE FROM neo_price WHERE quote_time < ?",
+		formatDBTime(time.Now().Add(-recorder.retention), recorder.db.DatabaseTZ),
+	)
+
+	return err
+}
+
+// current the latest prices of assets keyed by asset, no more than maxAge old
+func (recorder *priceRecorder) current(assets []string) (map[string]string, error) {
+	var rows []*PriceHistory
+
+	err := recorder.db.
+		Select("DISTINCT ON (asset) *").
+		Where("currency = ? and quote_time > ?",
+			recorder.currency, formatDBTime(time.Now().Add(-recorder.maxAge), recorder.db.DatabaseTZ)).
+		In("asset", assets).
+		OrderBy("asset, quote_time desc").
+		Find(&rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]string)
+
+	for _, row := range rows {
+		prices[row.Asset] = row.Price
+	}
+
+	return prices, nil
+}
+
+// at the latest price of asset quoted at or before t, and no more than maxAge earlier
+func (recorder *priceRecorder) at(asset string, t time.Time) (string, bool, error) {
+	row := new(PriceHistory)
+
+	ok, err := recorder.db.
+		Where("asset = ? and currency = ? and quote_time <= ? and quote_time > ?",
+			asset, recorder.currency,
+			formatDBTime(t, recorder.db.DatabaseTZ), formatDBTime(t.Add(-recorder.maxAge), recorder.db.DatabaseTZ)).
+		Desc("quote_time").
+		Get(row)
+
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	return row.Price, true, nil
+}
+
+// orderFiat set the fiat valuation of orders, converted from torders in the same order
+func (service *HTTPServer) orderFiat(orders []*Order, torders []*neodb.Order) error {
+	if service.prices == nil || len(torders) == 0 {
+		return nil
+	}
+
+	assets := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, torder := range torders {
+		if !seen[torder.Asset] {
+			seen[torder.Asset] = true
+			assets = append(assets, torder.Asset)
+		}
+	}
+
+	current, err := service.prices.current(assets)
+
+	if err != nil {
+		return err
+	}
+
+	// orders of the same tx share the price at confirm time
+	confirmPrices := make(map[string]string)
+
+	for i, torder := range torders {
+		order := orders[i]
+
+		fiat := &OrderFiat{Currency: service.prices.currency}
+
+		if value, ok := current[torder.Asset]; ok {
+			fiat.CurrentPrice = value
+
+			if fiat.CurrentValue, err = price.Value(order.Value, value, service.prices.decimals); err != nil {
+				return err
+			}
+		}
+
+		if torder.ConfirmTime != nil {
+			key := torder.TX + "|" + torder.Asset
+
+			value, ok := confirmPrices[key]
+
+			if !ok {
+				if value, _, err = service.prices.at(torder.Asset, *torder.ConfirmTime); err != nil {
+					return err
+				}
+
+				confirmPrices[key] = value
+			}
+
+			if value != "" {
+				fiat.Price = value
+
+				if fiat.Value, err = price.Value(order.Value, value, service.prices.decimals); err != nil {
+					return err
+				}
+			}
+		}
+
+		order.Fiat = fiat
+	}
+
+	return nil
+}
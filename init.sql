@@ -28,4 +28,287 @@ CREATE TABLE NEO_WALLET (
 
 
 CREATE UNIQUE INDEX NEO_WALLET_ADDRESS_USER
-  ON NEO_WALLET ("address", "userid");
\ No newline at end of file
+  ON NEO_WALLET ("address", "userid");
+
+DROP TABLE IF EXISTS NEO_ASSET;
+
+CREATE TABLE NEO_ASSET (
+  "id"          SERIAL PRIMARY KEY,
+  "asset"       VARCHAR(128) NOT NULL UNIQUE, -- global asset id or nep5 script hash
+  "symbol"      VARCHAR(32)  NOT NULL, -- asset symbol, e.g. GAS
+  "name"        VARCHAR(128) NOT NULL, -- asset display name
+  "decimals"    INTEGER      NOT NULL, -- asset decimals
+  "type"        VARCHAR(16)  NOT NULL, -- global or nep5
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW(),
+  "update_time" TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+
+DROP TABLE IF EXISTS NEO_NEP5_TX;
+
+CREATE TABLE NEO_NEP5_TX (
+  "id"          SERIAL PRIMARY KEY,
+  "t_x"         VARCHAR(128) NOT NULL, -- invocation tx id
+  "asset"       VARCHAR(128) NOT NULL, -- token contract script hash
+  "from"        VARCHAR(128) NOT NULL, -- transfer from address
+  "to"          VARCHAR(128) NOT NULL, -- transfer to address
+  "value"       NUMERIC      NOT NULL, -- decimal normalized transfer amount
+  "block"       BIGINT       NOT NULL,
+  "create_time" TIMESTAMP    NOT NULL
+);
+
+CREATE INDEX NEO_NEP5_TX_TX ON NEO_NEP5_TX ("t_x");
+CREATE INDEX NEO_NEP5_TX_FROM_TO ON NEO_NEP5_TX ("from", "to");
+
+
+DROP TABLE IF EXISTS NEO_WALLET_CHALLENGE;
+
+CREATE TABLE NEO_WALLET_CHALLENGE (
+  "id"          SERIAL PRIMARY KEY,
+  "nonce"       VARCHAR(64)  NOT NULL UNIQUE, -- random hex nonce to sign
+  "user_i_d"    VARCHAR(128) NOT NULL, -- user id requesting the registration
+  "address"     VARCHAR(128) NOT NULL, -- address to register
+  "expire_time" TIMESTAMP    NOT NULL,
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+
+DROP TABLE IF EXISTS NEO_RATE_LIMIT;
+
+CREATE TABLE NEO_RATE_LIMIT (
+  "id"          SERIAL PRIMARY KEY,
+  "key"         VARCHAR(256)     NOT NULL UNIQUE, -- route and client ip or user id
+  "tokens"      DOUBLE PRECISION NOT NULL, -- tokens left at update_time
+  "update_time" TIMESTAMP        NOT NULL
+);
+
+CREATE INDEX NEO_RATE_LIMIT_UPDATE_TIME ON NEO_RATE_LIMIT ("update_time"); -- purge of idle buckets
+
+
+DROP TABLE IF EXISTS NEO_WALLET_SETTINGS;
+
+CREATE TABLE NEO_WALLET_SETTINGS (
+  "id"          SERIAL PRIMARY KEY,
+  "user_i_d"    VARCHAR(128) NOT NULL,
+  "address"     VARCHAR(128) NOT NULL,
+  "label"       VARCHAR(64)  NOT NULL DEFAULT '', -- wallet nickname
+  "muted"       BOOLEAN      NOT NULL DEFAULT FALSE, -- no push for the wallet
+  "assets"      TEXT, -- json array of asset ids to push, all if null
+  "min_value"   VARCHAR(64)  NOT NULL DEFAULT '', -- push only orders of at least this value
+  "locale"      VARCHAR(16)  NOT NULL DEFAULT '', -- push message locale, default if empty
+  "channel"     VARCHAR(16)  NOT NULL DEFAULT '', -- notification channel, push if empty
+  "target"      VARCHAR(256) NOT NULL DEFAULT '', -- email address or telegram chat of the channel
+  "update_time" TIMESTAMP    NOT NULL DEFAULT NOW(),
+  UNIQUE ("user_i_d", "address")
+);
+
+
+DROP TABLE IF EXISTS NEO_WALLET_BACKFILL;
+
+CREATE TABLE NEO_WALLET_BACKFILL (
+  "id"          SERIAL PRIMARY KEY,
+  "address"     VARCHAR(128) NOT NULL UNIQUE,
+  "status"      VARCHAR(16)  NOT NULL, -- running, done or failed
+  "block"       BIGINT       NOT NULL DEFAULT 0, -- block of the last scanned transfer
+  "scanned"     BIGINT       NOT NULL DEFAULT 0, -- transfers scanned
+  "created"     BIGINT       NOT NULL DEFAULT 0, -- orders created or confirmed
+  "error"       TEXT,
+  "start_time"  TIMESTAMP    NOT NULL,
+  "finish_time" TIMESTAMP,
+  "update_time" TIMESTAMP    NOT NULL
+);
+
+
+-- order context is a JSON object stored as JSONB, legacy TEXT contexts are kept as {"memo": "..."}
+ALTER TABLE NEO_ORDER ADD COLUMN IF NOT EXISTS "context" TEXT;
+
+-- converted only while still TEXT, so the script can be rerun without nesting the contexts
+DO $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_schema = current_schema() AND table_name = 'neo_order'
+      AND column_name = 'context' AND data_type = 'text'
+  ) THEN
+    ALTER TABLE NEO_ORDER ALTER COLUMN "context" TYPE JSONB
+      USING CASE WHEN "context" IS NULL THEN NULL ELSE jsonb_build_object('memo', "context") END;
+  END IF;
+END
+$$;
+
+
+DROP TABLE IF EXISTS NEO_INVOICE;
+
+CREATE TABLE NEO_INVOICE (
+  "id"          SERIAL PRIMARY KEY,
+  "invoice_i_d" VARCHAR(32)  NOT NULL UNIQUE,
+  "user_i_d"    VARCHAR(128) NOT NULL DEFAULT '', -- user notified of the payments
+  "address"     VARCHAR(128) NOT NULL, -- recipient address
+  "asset"       VARCHAR(128) NOT NULL,
+  "amount"      NUMERIC      NOT NULL,
+  "paid"        NUMERIC      NOT NULL DEFAULT 0,
+  "memo"        VARCHAR(128) NOT NULL DEFAULT '',
+  "status"      VARCHAR(16)  NOT NULL, -- open, partial, paid, overpaid or expired
+  "expire_time" TIMESTAMP    NOT NULL,
+  "paid_time"   TIMESTAMP,
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX NEO_INVOICE_ADDRESS_ASSET ON NEO_INVOICE ("address", "asset");
+
+DROP TABLE IF EXISTS NEO_INVOICE_PAYMENT;
+
+CREATE TABLE NEO_INVOICE_PAYMENT (
+  "id"          SERIAL PRIMARY KEY,
+  "invoice_i_d" VARCHAR(32)  NOT NULL,
+  "order_i_d"   BIGINT       NOT NULL UNIQUE, -- one invoice per order
+  "t_x"         VARCHAR(128) NOT NULL,
+  "from"        VARCHAR(128) NOT NULL,
+  "value"       NUMERIC      NOT NULL,
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX NEO_INVOICE_PAYMENT_INVOICE ON NEO_INVOICE_PAYMENT ("invoice_i_d");
+
+
+DROP TABLE IF EXISTS NEO_TX_FEE;
+
+CREATE TABLE NEO_TX_FEE (
+  "id"          SERIAL PRIMARY KEY,
+  "t_x"         VARCHAR(128) NOT NULL UNIQUE,
+  "from"        VARCHAR(128) NOT NULL, -- the address paying the fee
+  "block"       BIGINT       NOT NULL,
+  "fee"         NUMERIC      NOT NULL, -- GAS inputs minus GAS outputs
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX NEO_TX_FEE_FROM ON NEO_TX_FEE ("from");
+
+
+DROP TABLE IF EXISTS NEO_ADDRESS_DAILY;
+
+CREATE TABLE NEO_ADDRESS_DAILY (
+  "id"           SERIAL PRIMARY KEY,
+  "address"      VARCHAR(128) NOT NULL,
+  "asset"        VARCHAR(128) NOT NULL,
+  "day"          DATE         NOT NULL, -- in the database timezone
+  "received"     NUMERIC      NOT NULL DEFAULT 0, -- from other addresses
+  "sent"         NUMERIC      NOT NULL DEFAULT 0, -- to other addresses, change excluded
+  "received_txs" BIGINT       NOT NULL DEFAULT 0,
+  "sent_txs"     BIGINT       NOT NULL DEFAULT 0,
+  "txs"          BIGINT       NOT NULL DEFAULT 0,
+  "first_time"   TIMESTAMP    NOT NULL,
+  "last_time"    TIMESTAMP    NOT NULL,
+  UNIQUE ("address", "asset", "day")
+);
+
+DROP TABLE IF EXISTS NEO_ADDRESS_STATS_TX;
+
+CREATE TABLE NEO_ADDRESS_STATS_TX (
+  "id"          SERIAL PRIMARY KEY,
+  "t_x"         VARCHAR(128) NOT NULL,
+  "address"     VARCHAR(128) NOT NULL,
+  "asset"       VARCHAR(128) NOT NULL,
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW(),
+  UNIQUE ("t_x", "address", "asset") -- a tx is counted once per address and asset
+);
+
+CREATE INDEX NEO_ADDRESS_STATS_TX_ADDRESS ON NEO_ADDRESS_STATS_TX ("address");
+
+
+DROP TABLE IF EXISTS NEO_PRICE;
+
+CREATE TABLE NEO_PRICE (
+  "id"         SERIAL PRIMARY KEY,
+  "asset"      VARCHAR(128) NOT NULL,
+  "currency"   VARCHAR(16)  NOT NULL,
+  "price"      NUMERIC      NOT NULL, -- of one asset unit in currency
+  "quote_time" TIMESTAMP    NOT NULL
+);
+
+CREATE INDEX NEO_PRICE_ASSET ON NEO_PRICE ("asset", "currency", "quote_time");
+CREATE INDEX NEO_PRICE_QUOTE_TIME ON NEO_PRICE ("quote_time"); -- purge of the prices past the retention
+
+
+DROP TABLE IF EXISTS NEO_CONTACT;
+
+CREATE TABLE NEO_CONTACT (
+  "id"          SERIAL PRIMARY KEY,
+  "user_i_d"    VARCHAR(128) NOT NULL,
+  "address"     VARCHAR(128) NOT NULL, -- counterparty address
+  "label"       VARCHAR(64)  NOT NULL,
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW(),
+  "update_time" TIMESTAMP    NOT NULL DEFAULT NOW(),
+  UNIQUE ("user_i_d", "address")
+);
+
+
+DROP TABLE IF EXISTS NEO_CHANNEL_TOKEN;
+
+CREATE TABLE NEO_CHANNEL_TOKEN (
+  "id"          SERIAL PRIMARY KEY,
+  "user_i_d"    VARCHAR(128) NOT NULL,
+  "channel"     VARCHAR(16)  NOT NULL, -- email or telegram
+  "target"      VARCHAR(256) NOT NULL, -- email address or telegram chat to confirm
+  "token"       VARCHAR(64)  NOT NULL,
+  "expire_time" TIMESTAMP    NOT NULL,
+  UNIQUE ("user_i_d", "channel", "target")
+);
+
+
+DROP TABLE IF EXISTS NEO_NOTIFICATION;
+
+CREATE TABLE NEO_NOTIFICATION (
+  "id"           SERIAL PRIMARY KEY,
+  "user_i_d"     VARCHAR(128) NOT NULL,
+  "channel"      VARCHAR(16)  NOT NULL, -- push, email or telegram
+  "category"     VARCHAR(16)  NOT NULL DEFAULT 'order', -- order, invoice or alert
+  "target"       VARCHAR(256) NOT NULL DEFAULT '', -- email address or telegram chat, empty for push
+  "event"        VARCHAR(16)  NOT NULL,
+  "message"      TEXT         NOT NULL,
+  "t_x"          VARCHAR(128) NOT NULL DEFAULT '',
+  "key"          VARCHAR(512) NOT NULL DEFAULT '', -- idempotency key of the tx notifications: event, tx, address, asset and invoice
+  "status"       VARCHAR(16)  NOT NULL, -- pending, delivered or failed
+  "attempts"     INT          NOT NULL DEFAULT 0,
+  "error"        TEXT         NOT NULL DEFAULT '', -- last delivery error
+  "next_time"    TIMESTAMP    NOT NULL, -- next delivery attempt, or end of the lease of a claimed notification
+  "create_time"  TIMESTAMP    NOT NULL DEFAULT NOW(),
+  "deliver_time" TIMESTAMP
+);
+
+CREATE INDEX NEO_NOTIFICATION_USER ON NEO_NOTIFICATION ("user_i_d", "id");
+
+CREATE INDEX NEO_NOTIFICATION_PENDING ON NEO_NOTIFICATION ("next_time") WHERE "status" = 'pending';
+
+CREATE UNIQUE INDEX NEO_NOTIFICATION_KEY ON NEO_NOTIFICATION ("user_i_d", "key") WHERE "key" <> '';
+
+DROP TABLE IF EXISTS NEO_ALERT;
+
+CREATE TABLE NEO_ALERT (
+  "id"          SERIAL PRIMARY KEY,
+  "user_i_d"    VARCHAR(128) NOT NULL,
+  "kind"        VARCHAR(16)  NOT NULL, -- large, counterparty or outgoing
+  "address"     VARCHAR(128) NOT NULL DEFAULT '', -- all the user wallets if empty, the watched address of outgoing rules
+  "asset"       VARCHAR(128) NOT NULL DEFAULT '', -- asset of large rules
+  "threshold"   VARCHAR(64)  NOT NULL DEFAULT '', -- min value of large rules
+  "create_time" TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX NEO_ALERT_USER ON NEO_ALERT ("user_i_d");
+
+CREATE INDEX NEO_ALERT_ADDRESS ON NEO_ALERT ("address");
+
+DROP TABLE IF EXISTS NEO_SUBSCRIPTION;
+
+CREATE TABLE NEO_SUBSCRIPTION (
+  "id"           SERIAL PRIMARY KEY,
+  "user_i_d"     VARCHAR(128) NOT NULL,
+  "kind"         VARCHAR(16)  NOT NULL, -- address or contract
+  "target"       VARCHAR(128) NOT NULL, -- watched address or nep5 contract script hash
+  "label"        VARCHAR(64)  NOT NULL DEFAULT '',
+  "hide_balance" BOOLEAN      NOT NULL DEFAULT FALSE, -- balance api refused to the user
+  "create_time"  TIMESTAMP    NOT NULL DEFAULT NOW(),
+  UNIQUE ("user_i_d", "kind", "target")
+);
+
+CREATE INDEX NEO_SUBSCRIPTION_TARGET ON NEO_SUBSCRIPTION ("target");
@@ -0,0 +1,493 @@
+package orderservice
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neodb"
+)
+
+// invoice status
+const (
+	invoiceOpen     = "open"
+	invoicePartial  = "partial"
+	invoicePaid     = "paid"
+	invoiceOverpaid = "overpaid"
+	invoiceExpired  = "expired"
+)
+
+// invoiceContextKey order context key referencing the invoice the transfer pays
+const invoiceContextKey = "invoice"
+
+// InvoiceTable payment request waiting for transfers to the recipient address
+type InvoiceTable struct {
+	ID         int64      `xorm:"pk autoincr"`
+	InvoiceID  string     `xorm:"notnull unique"`
+	UserID     string     `xorm:"notnull default ''"` // user notified of the payments
+	Address    string     `xorm:"notnull index(address_asset)"`
+	Asset      string     `xorm:"notnull index(address_asset)"`
+	Amount     string     `xorm:"notnull"`
+	Paid       string     `xorm:"notnull"`
+	Memo       string     `xorm:"notnull default ''"`
+	Status     string     `xorm:"notnull"`
+	ExpireTime time.Time  `xorm:"TIMESTAMP notnull"`
+	PaidTime   *time.Time `xorm:"TIMESTAMP"`
+	CreateTime time.Time  `xorm:"TIMESTAMP notnull created"`
+}
+
+// TableName xorm table name
+func (table *InvoiceTable) TableName() string {
+	return "neo_invoice"
+}
+
+// InvoicePayment order matched to an invoice, one invoice per order
+type InvoicePayment struct {
+	ID         int64     `xorm:"pk autoincr"`
+	InvoiceID  string    `xorm:"notnull index"`
+	OrderID    int64     `xorm:"notnull unique"`
+	TX         string    `xorm:"notnull"`
+	From       string    `xorm:"notnull"`
+	Value      string    `xorm:"notnull"`
+	CreateTime time.Time `xorm:"TIMESTAMP notnull created"`
+}
+
+// TableName xorm table name
+func (table *InvoicePayment) TableName() string {
+	return "neo_invoice_payment"
+}
+
+// Invoice invoice api object
+type Invoice struct {
+	ID         string `json:"id"`
+	UserID     string `json:"userid,omitempty"`
+	Address    string `json:"address" binding:"required"`
+	Asset      string `json:"asset" binding:"required"`
+	AssetName  string `json:"assetName,omitempty"`
+	Amount     string `json:"amount" binding:"required"`
+	Paid       string `json:"paid"`
+	Memo       string `json:"memo,omitempty"`
+	Status     string `json:"status"`
+	URI        string `json:"uri"`
+	ExpireTime string `json:"expireTime"`
+	PaidTime   string `json:"paidTime,omitempty"`
+	CreateTime string `json:"createTime"`
+}
+
+// invoiceStatus status of invoice paid of amount
+func invoiceStatus(paid Amount, amount Amount) string {
+	switch cmp := paid.Cmp(amount); {
+	case cmp > 0:
+		return invoiceOverpaid
+	case cmp == 0:
+		return invoicePaid
+	case paid.IsPositive():
+		return invoicePartial
+	default:
+		return invoiceOpen
+	}
+}
+
+// invoiceURI NEP-9 payment uri of invoice
+func invoiceURI(invoice *InvoiceTable) string {
+	asset := invoice.Asset
+
+	switch asset {
+	case neoAsset:
+		asset = "neo"
+	case gasAsset:
+		asset = "gas"
+	}
+
+	query := url.Values{
+		"asset":  {asset},
+		"amount": {invoice.Amount},
+	}
+
+	if invoice.Memo != "" {
+		query.Set("description", invoice.Memo)
+	}
+
+	return fmt.Sprintf("neo:%s?%s", invoice.Address, query.Encode())
+}
+
+func (service *HTTPServer) newInvoice(invoice *InvoiceTable) *Invoice {
+	result := &Invoice{
+		ID:         invoice.InvoiceID,
+		UserID:     invoice.UserID,
+		Address:    invoice.Address,
+		Asset:      invoice.Asset,
+		AssetName:  service.assets.name(invoice.Asset),
+		Amount:     invoice.Amount,
+		Paid:       invoice.Paid,
+		Memo:       invoice.Memo,
+		Status:     invoice.Status,
+		URI:        invoiceURI(invoice),
+		ExpireTime: invoice.ExpireTime.Format(time.RFC3339Nano),
+		CreateTime: invoice.CreateTime.Format(time.RFC3339Nano),
+	}
+
+	// the watcher expires the open and partially paid invoices every minute, report them expired
+	// in between as well
+	if (invoice.Status == invoiceOpen || invoice.Status == invoicePartial) && time.Now().After(invoice.ExpireTime) {
+		result.Status = invoiceExpired
+	}
+
+	if invoice.PaidTime != nil {
+		result.PaidTime = invoice.PaidTime.Format(time.RFC3339Nano)
+	}
+
+	return result
+}
+
+// validateInvoice validate and normalize create invoice request
+func (service *HTTPServer) validateInvoice(invoice *Invoice) (err error) {
+	if invoice.Address, err = validateAddress("address", invoice.Address); err != nil {
+		return err
+	}
+
+	if invoice.Asset, err = service.validateAsset("asset", invoice.Asset); err != nil {
+		return err
+	}
+
+	amount, err := service.assets.parseValue(invoice.Asset, invoice.Amount)
+
+	if err != nil {
+		return newValidationError("amount", "%s", err)
+	}
+
+	if !amount.IsPositive() {
+		return newValidationError("amount", "amount must be positive")
+	}
+
+	invoice.Amount = amount.String()
+
+	if len(invoice.Memo) > 128 {
+		return newValidationError("memo", "memo must be at most 128 chars")
+	}
+
+	if invoice.ExpireTime == "" {
+		invoice.ExpireTime = time.Now().Add(service.invoiceExpire).Format(time.RFC3339Nano)
+	} else {
+		expireTime, err := time.Parse(time.RFC3339Nano, invoice.ExpireTime)
+
+		if err != nil {
+			return newValidationError("expireTime", "expireTime must be RFC3339 time")
+		}
+
+		if !expireTime.After(time.Now()) {
+			return newValidationError("expireTime", "expireTime must be in the future")
+		}
+	}
+
+	return nil
+}
+
+func (service *HTTPServer) createInvoice(invoice *Invoice) (*Invoice, error) {
+	buff := make([]byte, 16)
+
+	if _, err := rand.Read(buff); err != nil {
+		return nil, err
+	}
+
+	expireTime, _ := time.Parse(time.RFC3339Nano, invoice.ExpireTime)
+
+	tInvoice := &InvoiceTable{
+		InvoiceID:  hex.EncodeToString(buff),
+		UserID:     invoice.UserID,
+		Address:    invoice.Address,
+		Asset:      invoice.Asset,
+		Amount:     invoice.Amount,
+		Paid:       "0",
+		Memo:       invoice.Memo,
+		Status:     invoiceOpen,
+		ExpireTime: expireTime,
+	}
+
+	if _, err := service.db.Insert(tInvoice); err != nil {
+		return nil, err
+	}
+
+	return service.newInvoice(tInvoice), nil
+}
+
+func (service *HTTPServer) getInvoice(id string) (*Invoice, error) {
+	invoice := new(InvoiceTable)
+
+	ok, err := service.db.Where("invoice_i_d = ?", id).Get(invoice)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errNotFound("invoice %s not found", id)
+	}
+
+	return service.newInvoice(invoice), nil
+}
+
+// hasOpenInvoice check if address has unpaid invoices of asset, so the transfers
+// to it are tracked as orders even if the address is not a registered wallet
+func (watcher *TxWatcher) hasOpenInvoice(address string, asset string) (bool, error) {
+	return watcher.db.
+		Where(`"address" = ? and asset = ? and status in (?, ?) and expire_time > ?`,
+			address, asset, invoiceOpen, invoicePartial, formatDBTime(time.Now(), watcher.db.DatabaseTZ)).
+		Exist(new(InvoiceTable))
+}
+
+// orderInvoiceRef the invoice id or memo referenced by the order context, if any
+func orderInvoiceRef(order *neodb.Order) string {
+	if order.Context == nil {
+		return ""
+	}
+
+	var context map[string]interface{}
+
+	if err := json.Unmarshal([]byte(*order.Context), &context); err != nil {
+		return ""
+	}
+
+	if id, ok := context[invoiceContextKey].(string); ok {
+		return id
+	}
+
+	memo, _ := context["memo"].(string)
+
+	return memo
+}
+
+// matchInvoices apply the confirmed orders to the open invoices of their recipient
+func (watcher *TxWatcher) matchInvoices(orders []*neodb.Order) error {
+	for _, order := range orders {
+		if order.ConfirmTime == nil {
+			continue
+		}
+
+		invoice, err := watcher.matchInvoice(order)
+
+		if err != nil {
+			return err
+		}
+
+		if invoice == nil {
+			continue
+		}
+
+		watcher.DebugF("order %s pays invoice %s, %s", order.TX, invoice.InvoiceID, invoice.Status)
+	}
+
+	return nil
+}
+
+// matchInvoice find the invoice paid by order and record the payment along with its notification,
+// the invoice is the one referenced by the order context or memo, else the oldest one of the
+// exact remaining amount, else the only open invoice of the recipient and asset
+func (watcher *TxWatcher) matchInvoice(order *neodb.Order) (invoice *InvoiceTable, err error) {
+	session := watcher.db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
+
+	matched, err := session.Where("order_i_d = ?", order.ID).Exist(new(InvoicePayment))
+
+	if err != nil || matched {
+		return nil, err
+	}
+
+	var invoices []*InvoiceTable
+
+	err = session.
+		Where(`"address" = ? and asset = ? and status in (?, ?) and expire_time > ?`,
+			order.To, order.Asset, invoiceOpen, invoicePartial, formatDBTime(*order.ConfirmTime, watcher.db.DatabaseTZ)).
+		Asc("create_time", "id").
+		ForUpdate().
+		Find(&invoices)
+
+	if err != nil || len(invoices) == 0 {
+		return nil, err
+	}
+
+	value, err := watcher.assets.parseValue(order.Asset, order.Value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invoice = selectInvoice(invoices, orderInvoiceRef(order), value, watcher.assets)
+
+	if invoice == nil {
+		return nil, session.Commit()
+	}
+
+	paid, err := watcher.assets.parseValue(invoice.Asset, invoice.Paid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if paid, err = paid.Add(value); err != nil {
+		return nil, err
+	}
+
+	amount, err := watcher.assets.parseValue(invoice.Asset, invoice.Amount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invoice.Paid = paid.String()
+	invoice.Status = invoiceStatus(paid, amount)
+
+	if invoice.Status != invoicePartial && invoice.PaidTime == nil {
+		invoice.PaidTime = order.ConfirmTime
+	}
+
+	payment := &InvoicePayment{
+		InvoiceID: invoice.InvoiceID,
+		OrderID:   order.ID,
+		TX:        order.TX,
+		From:      order.From,
+		Value:     order.Value,
+	}
+
+	if _, err = session.Insert(payment); err != nil {
+		return nil, err
+	}
+
+	if _, err = session.ID(invoice.ID).Cols("paid", "status", "paid_time").Update(invoice); err != nil {
+		return nil, err
+	}
+
+	if err = watcher.queueInvoice(session, invoice, pushPaid, order.TX); err != nil {
+		return nil, err
+	}
+
+	return invoice, session.Commit()
+}
+
+func selectInvoice(invoices []*InvoiceTable, ref string, value Amount, assets *assetRegistry) *InvoiceTable {
+	for _, invoice := range invoices {
+		if ref != "" && (invoice.InvoiceID == ref || invoice.Memo == ref) {
+			return invoice
+		}
+	}
+
+	for _, invoice := range invoices {
+		remaining, err := invoiceRemaining(invoice, assets)
+
+		if err == nil && remaining.Cmp(value) == 0 {
+			return invoice
+		}
+	}
+
+	if len(invoices) == 1 && ref == "" {
+		return invoices[0]
+	}
+
+	return nil
+}
+
+func invoiceRemaining(invoice *InvoiceTable, assets *assetRegistry) (Amount, error) {
+	amount, err := assets.parseValue(invoice.Asset, invoice.Amount)
+
+	if err != nil {
+		return Amount{}, err
+	}
+
+	paid, err := assets.parseValue(invoice.Asset, invoice.Paid)
+
+	if err != nil {
+		return Amount{}, err
+	}
+
+	return amount.Sub(paid)
+}
+
+// queueInvoice queue the notification of event of invoice to the user of the invoice, if any
+func (watcher *TxWatcher) queueInvoice(session *xorm.Session, invoice *InvoiceTable, event string, tx string) error {
+	if invoice.UserID == "" {
+		return nil
+	}
+
+	return watcher.queue(session, invoice.UserID, invoice.Address, event, &PushData{
+		Address:       invoice.Address,
+		Asset:         invoice.Asset,
+		AssetName:     watcher.assets.name(invoice.Asset),
+		TX:            tx,
+		InvoiceID:     invoice.InvoiceID,
+		InvoiceStatus: invoice.Status,
+		Amount:        invoice.Amount,
+		Paid:          invoice.Paid,
+		Memo:          invoice.Memo,
+	})
+}
+
+// expireInvoices mark the open and partially paid invoices past their expire time expired and queue the
+// expiry notifications in the same transaction
+func (watcher *TxWatcher) expireInvoices() (expired int, err error) {
+	session := watcher.db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
+
+	var invoices []*InvoiceTable
+
+	err = session.SQL(
+		"UPDATE neo_invoice SET status = ? WHERE status in (?, ?) and expire_time <= ? RETURNING *",
+		invoiceExpired, invoiceOpen, invoicePartial, formatDBTime(time.Now(), watcher.db.DatabaseTZ),
+	).Find(&invoices)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, invoice := range invoices {
+		if err = watcher.queueInvoice(session, invoice, pushExpired, ""); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(invoices), session.Commit()
+}
+
+// runExpire expire the invoices past their expire time periodically
+func (watcher *TxWatcher) runExpire() {
+	ticker := time.NewTicker(watcher.expireCheck)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := watcher.expireInvoices()
+
+		if err != nil {
+			watcher.ErrorF("expire invoices error, %s", err)
+			continue
+		}
+
+		if expired > 0 {
+			watcher.DebugF("expired %d invoices", expired)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package orderservice
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// jwtClaims the registered claims used by the service, sub is the userid
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  interface{} `json:"aud"`
+	ExpiresAt *float64    `json:"exp"`
+	NotBefore *float64    `json:"nbf"`
+	Admin     bool        `json:"admin"`
+}
+
+// hasAudience check aud claim, which may be a string or a string array
+func (claims *jwtClaims) hasAudience(audience string) bool {
+	switch aud := claims.Audience.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// jwtVerifier HS256/RS256 jwt verifier
+type jwtVerifier struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	issuer    string
+	audience  string
+	leeway    time.Duration
+}
+
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem file %s", path)
+	}
+
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("certificate %s is not a rsa certificate", path)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not a rsa key", path)
+	}
+
+	return rsaKey, nil
+}
+
+// verify check token signature and time claims, returns the claims
+func (verifier *jwtVerifier) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed jwt")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch header.Alg {
+	case "HS256":
+		if len(verifier.secret) == 0 {
+			return nil, fmt.Errorf("HS256 jwt not accepted")
+		}
+
+		mac := hmac.New(sha256.New, verifier.secret)
+		mac.Write(signed)
+
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, fmt.Errorf("invalid jwt signature")
+		}
+	case "RS256":
+		if verifier.publicKey == nil {
+			return nil, fmt.Errorf("RS256 jwt not accepted")
+		}
+
+		digest := sha256.Sum256(signed)
+
+		if err := rsa.VerifyPKCS1v15(verifier.publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, fmt.Errorf("invalid jwt signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported jwt alg %s", header.Alg)
+	}
+
+	var claims jwtClaims
+
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("jwt exp claim required")
+	}
+
+	if now.After(time.Unix(int64(*claims.ExpiresAt), 0).Add(verifier.leeway)) {
+		return nil, fmt.Errorf("jwt expired")
+	}
+
+	if claims.NotBefore != nil && now.Before(time.Unix(int64(*claims.NotBefore), 0).Add(-verifier.leeway)) {
+		return nil, fmt.Errorf("jwt not valid yet")
+	}
+
+	if verifier.issuer != "" && claims.Issuer != verifier.issuer {
+		return nil, fmt.Errorf("unexpected jwt issuer")
+	}
+
+	if verifier.audience != "" && !claims.hasAudience(verifier.audience) {
+		return nil, fmt.Errorf("unexpected jwt audience")
+	}
+
+	if claims.Subject == "" && !claims.Admin {
+		return nil, fmt.Errorf("jwt sub claim required")
+	}
+
+	return &claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+
+	if err != nil {
+		return fmt.Errorf("malformed jwt")
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("malformed jwt")
+	}
+
+	return nil
+}
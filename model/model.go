@@ -177,7 +177,7 @@ type Tx struct {
 	Address    string
 	Type       string
 	Assert     string
-	Value      float64
+	Value      string
 	UpdateTime string
 }
 
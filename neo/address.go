@@ -0,0 +1,125 @@
+package neo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// AddressVersion NEO address version byte
+const AddressVersion = 0x17
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Base58Decode decode bitcoin alphabet base58 string
+func Base58Decode(value string) ([]byte, error) {
+	result := big.NewInt(0)
+	radix := big.NewInt(58)
+
+	for _, c := range value {
+		index := strings.IndexRune(base58Alphabet, c)
+
+		if index < 0 {
+			return nil, fmt.Errorf("invalid base58 char %q", c)
+		}
+
+		result.Mul(result, radix)
+		result.Add(result, big.NewInt(int64(index)))
+	}
+
+	decoded := result.Bytes()
+
+	var zeros int
+
+	for zeros < len(value) && value[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), decoded...), nil
+}
+
+// Base58Encode encode data as bitcoin alphabet base58 string
+func Base58Encode(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var result []byte
+
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, mod)
+		result = append(result, base58Alphabet[mod.Int64()])
+	}
+
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+
+		result = append(result, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return string(result)
+}
+
+func checksum(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+
+	return second[:4]
+}
+
+// DecodeAddress decode NEO Base58Check address to the 20 bytes script hash
+func DecodeAddress(address string) ([]byte, error) {
+	data, err := Base58Decode(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) != 25 {
+		return nil, fmt.Errorf("address must be 25 bytes")
+	}
+
+	if data[0] != AddressVersion {
+		return nil, fmt.Errorf("address version must be 0x%02x", AddressVersion)
+	}
+
+	if !bytes.Equal(checksum(data[:21]), data[21:]) {
+		return nil, fmt.Errorf("address checksum mismatch")
+	}
+
+	return data[1:21], nil
+}
+
+// EncodeAddress encode 20 bytes script hash as NEO Base58Check address
+func EncodeAddress(scriptHash []byte) string {
+	data := append([]byte{AddressVersion}, scriptHash...)
+
+	return Base58Encode(append(data, checksum(data)...))
+}
+
+// ScriptHash hash script as RIPEMD160(SHA256(script))
+func ScriptHash(script []byte) []byte {
+	first := sha256.Sum256(script)
+	second := RIPEMD160(first[:])
+
+	return second[:]
+}
+
+// VerificationScript build the single signature verification script of compressed public key
+func VerificationScript(compressed []byte) []byte {
+	script := make([]byte, 0, len(compressed)+2)
+
+	script = append(script, byte(len(compressed))) // PUSHBYTES33
+	script = append(script, compressed...)
+	script = append(script, 0xac) // CHECKSIG
+
+	return script
+}
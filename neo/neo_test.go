@@ -0,0 +1,71 @@
+package neo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRIPEMD160(t *testing.T) {
+	vectors := map[string]string{
+		"":    "9c1185a5c5e9fc54612808977ee8f548b2258d31",
+		"abc": "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc",
+		"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq": "12a053384a9c0c88e405a06c27dcf49ada62eb2b",
+		strings.Repeat("1234567890", 8):                            "9b752e45573d4b39f4dbd3323cab82bf63326bfb",
+	}
+
+	for message, digest := range vectors {
+		sum := RIPEMD160([]byte(message))
+		assert.Equal(t, digest, hex.EncodeToString(sum[:]), message)
+	}
+}
+
+func TestPublicKeyAddress(t *testing.T) {
+	data, err := hex.DecodeString("031a6c6fbbdf02ca351745fa86b9ba5a9452d785ac4f7fc2b7548ca2a46c4fcf4a")
+	assert.NoError(t, err)
+
+	key, err := ParsePublicKey(data)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, data, CompressPublicKey(key))
+		assert.Equal(t, "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", PublicKeyAddress(key))
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	message := []byte("3f6c1e0d9b8a7c6d5e4f3a2b1c0d9e8f")
+	digest := sha256.Sum256(message)
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	assert.NoError(t, err)
+
+	signature := make([]byte, 64)
+	copy(signature[32-len(r.Bytes()):32], r.Bytes())
+	copy(signature[64-len(s.Bytes()):], s.Bytes())
+
+	parsed, err := ParsePublicKey(CompressPublicKey(&key.PublicKey))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, parsed.Y.Cmp(key.Y))
+		assert.True(t, VerifySignature(parsed, message, signature))
+		assert.False(t, VerifySignature(parsed, []byte("other"), signature))
+		assert.False(t, VerifySignature(parsed, message, append(signature[:63:63], signature[63]^1)))
+	}
+
+	address := PublicKeyAddress(&key.PublicKey)
+
+	scriptHash, err := DecodeAddress(address)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, ScriptHash(VerificationScript(CompressPublicKey(&key.PublicKey))), scriptHash)
+	}
+}
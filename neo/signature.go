@@ -0,0 +1,96 @@
+package neo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// ParsePublicKey parse compressed (33 bytes) or uncompressed (65 bytes) secp256r1 public key
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+	params := curve.Params()
+
+	switch {
+	case len(data) == 65 && data[0] == 0x04:
+		x := new(big.Int).SetBytes(data[1:33])
+		y := new(big.Int).SetBytes(data[33:])
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("public key not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case len(data) == 33 && (data[0] == 0x02 || data[0] == 0x03):
+		x := new(big.Int).SetBytes(data[1:])
+
+		if x.Cmp(params.P) >= 0 {
+			return nil, fmt.Errorf("public key not on curve")
+		}
+
+		// y^2 = x^3 - 3x + b
+		y := new(big.Int).Mul(x, x)
+		y.Mul(y, x)
+		y.Sub(y, new(big.Int).Mul(big.NewInt(3), x))
+		y.Add(y, params.B)
+		y.Mod(y, params.P)
+
+		if y.ModSqrt(y, params.P) == nil {
+			return nil, fmt.Errorf("public key not on curve")
+		}
+
+		if y.Bit(0) != uint(data[0]&1) {
+			y.Sub(params.P, y)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("public key must be 33 bytes compressed or 65 bytes uncompressed")
+	}
+}
+
+// CompressPublicKey encode public key in the 33 bytes compressed form
+func CompressPublicKey(key *ecdsa.PublicKey) []byte {
+	compressed := make([]byte, 33)
+
+	compressed[0] = 0x02 | byte(key.Y.Bit(0))
+
+	x := key.X.Bytes()
+
+	copy(compressed[33-len(x):], x)
+
+	return compressed
+}
+
+// PublicKeyAddress derive the NEO address of the single signature contract of key
+func PublicKeyAddress(key *ecdsa.PublicKey) string {
+	return EncodeAddress(ScriptHash(VerificationScript(CompressPublicKey(key))))
+}
+
+// VerifySignature verify SHA256withECDSA signature of message, signature is either the
+// 64 bytes r||s form used by NEO wallets or ASN.1 DER
+func VerifySignature(key *ecdsa.PublicKey, message []byte, signature []byte) bool {
+	var r, s *big.Int
+
+	if len(signature) == 64 {
+		r = new(big.Int).SetBytes(signature[:32])
+		s = new(big.Int).SetBytes(signature[32:])
+	} else {
+		var der struct {
+			R, S *big.Int
+		}
+
+		if rest, err := asn1.Unmarshal(signature, &der); err != nil || len(rest) != 0 {
+			return false
+		}
+
+		r, s = der.R, der.S
+	}
+
+	digest := sha256.Sum256(message)
+
+	return ecdsa.Verify(key, digest[:], r, s)
+}
@@ -0,0 +1,57 @@
+package orderservice
+
+import (
+	"time"
+
+	"github.com/inwecrypto/neodb"
+)
+
+// NEP5Tx nep5 transfer notification indexed from the contract transfer events
+type NEP5Tx struct {
+	ID         int64     `xorm:"pk autoincr"`
+	TX         string    `xorm:"notnull index"`
+	Asset      string    `xorm:"notnull index"` // token contract script hash
+	From       string    `xorm:"index(from_to)"`
+	To         string    `xorm:"index(from_to)"`
+	Value      string    `xorm:"notnull"` // decimal normalized transfer amount
+	Block      uint64    `xorm:"notnull index"`
+	CreateTime time.Time `xorm:"TIMESTAMP notnull"`
+}
+
+// TableName xorm table name
+func (table *NEP5Tx) TableName() string {
+	return "neo_nep5_tx"
+}
+
+// neoTx convert the transfer to the neo_tx form the orders are created from
+func (table *NEP5Tx) neoTx() *neodb.Tx {
+	return &neodb.Tx{
+		ID:         table.ID,
+		TX:         table.TX,
+		From:       table.From,
+		To:         table.To,
+		Asset:      normalizeAssetID(table.Asset),
+		Value:      table.Value,
+		Block:      table.Block,
+		CreateTime: table.CreateTime,
+	}
+}
+
+// confirmNEP5 handle nep5 transfer event of tx
+func (watcher *TxWatcher) confirmNEP5(txid string) error {
+	watcher.DebugF("handle nep5 tx %s", txid)
+
+	nep5Txs := make([]*NEP5Tx, 0)
+
+	if err := watcher.db.Where("t_x = ?", txid).Find(&nep5Txs); err != nil {
+		return err
+	}
+
+	txs := make([]*neodb.Tx, 0, len(nep5Txs))
+
+	for _, nep5Tx := range nep5Txs {
+		txs = append(txs, nep5Tx.neoTx())
+	}
+
+	return watcher.confirmTxs(txid, txs)
+}
@@ -0,0 +1,123 @@
+// Package notify email and telegram senders of the order notifications
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPSender send plain text emails through an SMTP server
+type SMTPSender struct {
+	addr     string // host:port
+	from     string
+	username string
+	password string
+}
+
+// NewSMTPSender create a smtp sender, without username the mails are sent unauthenticated
+func NewSMTPSender(addr string, from string, username string, password string) *SMTPSender {
+	return &SMTPSender{
+		addr:     addr,
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+// Send send the mail of subject and body to the to address
+func (sender *SMTPSender) Send(to string, subject string, body string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", to)
+	}
+
+	var auth smtp.Auth
+
+	if sender.username != "" {
+		host, _, err := net.SplitHostPort(sender.addr)
+
+		if err != nil {
+			return err
+		}
+
+		auth = smtp.PlainAuth("", sender.username, sender.password, host)
+	}
+
+	var message bytes.Buffer
+
+	fmt.Fprintf(&message, "From: %s\r\n", sender.from)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	message.WriteString("\r\n")
+
+	return smtp.SendMail(sender.addr, auth, sender.from, []string{to}, message.Bytes())
+}
+
+// TelegramSender send messages by the Telegram Bot API
+type TelegramSender struct {
+	api    string // bot api base url, e.g. https://api.telegram.org
+	token  string
+	client *http.Client
+}
+
+// NewTelegramSender create a telegram sender of the bot token
+func NewTelegramSender(api string, token string, timeout time.Duration) *TelegramSender {
+	return &TelegramSender{
+		api:    strings.TrimRight(api, "/"),
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+// Send send text to the chat, a chat id or @channel username
+func (sender *TelegramSender) Send(chat string, text string) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": chat,
+		"text":    text,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := sender.client.Post(
+		fmt.Sprintf("%s/bot%s/sendMessage", sender.api, sender.token),
+		"application/json", bytes.NewReader(body),
+	)
+
+	if err != nil {
+		// the error message holds the url, do not leak the bot token
+		return fmt.Errorf("telegram sendMessage error, %s", strings.Replace(err.Error(), sender.token, "***", -1))
+	}
+
+	defer resp.Body.Close()
+
+	var result telegramResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram sendMessage status %d", resp.StatusCode)
+	}
+
+	if !result.OK {
+		return fmt.Errorf("telegram sendMessage error %d, %s", result.ErrorCode, result.Description)
+	}
+
+	return nil
+}
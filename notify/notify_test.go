@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpMail one mail received by the stand-in smtp server
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP a minimal smtp server accepting the mails of one connection
+func serveSMTP(listener net.Listener, mails chan<- *smtpMail) {
+	conn, err := listener.Accept()
+
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+
+	mail := new(smtpMail)
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+
+			var data []string
+
+			for {
+				line, err := reader.ReadString('\n')
+
+				if err != nil {
+					return
+				}
+
+				if line == ".\r\n" {
+					break
+				}
+
+				data = append(data, line)
+			}
+
+			mail.data = strings.Join(data, "")
+			mails <- mail
+			mail = new(smtpMail)
+
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer listener.Close()
+
+	mails := make(chan *smtpMail, 1)
+
+	go serveSMTP(listener, mails)
+
+	sender := NewSMTPSender(listener.Addr().String(), "alerts@example.com", "", "")
+
+	err = sender.Send("alice@example.com", "InWeCrypto", "received 10 NEO from Alice, tx 0x1234")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	select {
+	case mail := <-mails:
+		assert.Equal(t, "alerts@example.com", mail.from)
+		assert.Equal(t, []string{"alice@example.com"}, mail.to)
+		assert.Contains(t, mail.data, "To: alice@example.com\r\n")
+		assert.Contains(t, mail.data, "Subject: InWeCrypto\r\n")
+		assert.Contains(t, mail.data, "\r\n\r\nreceived 10 NEO from Alice, tx 0x1234\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("mail not received")
+	}
+}
+
+func TestSMTPSenderInvalidRecipient(t *testing.T) {
+	sender := NewSMTPSender("127.0.0.1:1", "alerts@example.com", "", "")
+
+	assert.Error(t, sender.Send("alice@example.com\r\nBcc: eve@example.com", "InWeCrypto", "hello"))
+}
+
+func TestTelegramSender(t *testing.T) {
+	var received map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:secret/sendMessage" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+			return
+		}
+
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+		if received["chat_id"] == "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+			return
+		}
+
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+
+	defer server.Close()
+
+	sender := NewTelegramSender(server.URL, "123:secret", time.Second)
+
+	if assert.NoError(t, sender.Send("123456789", "received 10 NEO")) {
+		assert.Equal(t, map[string]string{"chat_id": "123456789", "text": "received 10 NEO"}, received)
+	}
+
+	err := sender.Send("42", "received 10 NEO")
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "chat not found")
+	}
+
+	err = NewTelegramSender(server.URL, "123:other", time.Second).Send("123456789", "received 10 NEO")
+	assert.Error(t, err)
+}
+
+func TestTelegramSenderHidesToken(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// nothing listens on the address once closed
+	addr := listener.Addr().String()
+	listener.Close()
+
+	err = NewTelegramSender("http://"+addr, "123:secret", time.Second).Send("123456789", "hello")
+
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "secret")
+	}
+}
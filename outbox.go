@@ -0,0 +1,417 @@
+package orderservice
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/denverdino/aliyungo/push"
+	"github.com/dynamicgo/config"
+	"github.com/dynamicgo/slf4go"
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neo-order-service/notify"
+)
+
+// notification status
+const (
+	notificationPending   = "pending"
+	notificationDelivered = "delivered"
+	notificationFailed    = "failed" // gave up after the max attempts
+)
+
+// notification channels
+const (
+	channelPush     = "push"     // aliyun mobile push to the userid account
+	channelEmail    = "email"    // mail to the target address
+	channelTelegram = "telegram" // telegram bot message to the target chat
+)
+
+// maxNotificationBackoff the max delay between two delivery attempts
+const maxNotificationBackoff = time.Hour
+
+// Notification outbox record of a message to deliver to a user, written in the
+// transaction of the change it notifies and delivered at least once by the dispatcher
+type Notification struct {
+	ID          int64      `json:"id" xorm:"pk autoincr"`
+	UserID      string     `json:"userid" xorm:"notnull index"`
+	Channel     string     `json:"channel" xorm:"notnull"`
+	Target      string     `json:"target,omitempty" xorm:"notnull default ''"` // email address or chat, empty for push
+	Category    string     `json:"category" xorm:"notnull default 'order'"`    // order, invoice or alert
+	Event       string     `json:"event" xorm:"notnull"`
+	Message     string     `json:"message" xorm:"TEXT notnull"`
+	TX          string     `json:"tx,omitempty" xorm:"notnull default ''"`
+	Key         string     `json:"-" xorm:"notnull default ''"` // idempotency key unique per user, none if empty
+	Status      string     `json:"status" xorm:"notnull index(status_next)"`
+	Attempts    int        `json:"attempts" xorm:"notnull default 0"`
+	Error       string     `json:"error,omitempty" xorm:"TEXT notnull default ''"`
+	NextTime    time.Time  `json:"-" xorm:"TIMESTAMP notnull index(status_next)"` // next delivery attempt
+	CreateTime  time.Time  `json:"createTime" xorm:"TIMESTAMP notnull created"`
+	DeliverTime *time.Time `json:"deliverTime,omitempty" xorm:"TIMESTAMP"`
+}
+
+// TableName xorm table name
+func (table *Notification) TableName() string {
+	return "neo_notification"
+}
+
+// notifier deliver notifications of one channel
+type notifier interface {
+	notify(notification *Notification) error
+}
+
+// aliyunNotifier deliver notifications by aliyun push to the account of the userid
+type aliyunNotifier struct {
+	client *push.Client
+	appkey int64
+	title  string
+}
+
+func newAliyunNotifier(cnf *config.Config) *aliyunNotifier {
+	return &aliyunNotifier{
+		client: push.NewClient(
+			cnf.GetString("nos.push.user", "xxxx"),
+			cnf.GetString("nos.push.password", "xxxxx"),
+		),
+		appkey: cnf.GetInt64("nos.push.appkey", 0),
+		title:  cnf.GetString("nos.push.title", "InWeCrypto"),
+	}
+}
+
+// emailNotifier deliver notifications by mail to the target address
+type emailNotifier struct {
+	sender  *notify.SMTPSender
+	subject string
+}
+
+func (notifier *emailNotifier) notify(notification *Notification) error {
+	return notifier.sender.Send(notification.Target, notifier.subject, notification.Message)
+}
+
+// telegramNotifier deliver notifications by the telegram bot to the target chat
+type telegramNotifier struct {
+	sender *notify.TelegramSender
+}
+
+func (notifier *telegramNotifier) notify(notification *Notification) error {
+	return notifier.sender.Send(notification.Target, notification.Message)
+}
+
+// newNotifiers create the notifiers of the channels enabled by order.notify.channels, aliyun push
+// is always enabled. The http server validates the channels of the users and the dispatcher delivers
+// them from the same list, a channel enabled without its sender config, or configured but not
+// enabled, fails the startup of both
+func newNotifiers(cnf *config.Config) (map[string]notifier, error) {
+	notifiers := map[string]notifier{
+		channelPush: newAliyunNotifier(cnf),
+	}
+
+	enabled := []string{channelPush}
+
+	if cnf.Has("order.notify.channels") {
+		if err := cnf.GetObject("order.notify.channels", &enabled); err != nil {
+			return nil, err
+		}
+	}
+
+	channels := make(map[string]bool)
+
+	for _, channel := range enabled {
+		switch channel {
+		case channelPush, channelEmail, channelTelegram:
+			channels[channel] = true
+		default:
+			return nil, fmt.Errorf("order.notify.channels: unknown channel %s", channel)
+		}
+	}
+
+	addr := cnf.GetString("order.notify.smtp.addr", "")
+
+	if channels[channelEmail] != (addr != "") {
+		return nil, fmt.Errorf("order.notify.smtp.addr must be set if and only if %s is in order.notify.channels", channelEmail)
+	}
+
+	if addr != "" {
+		notifiers[channelEmail] = &emailNotifier{
+			sender: notify.NewSMTPSender(
+				addr,
+				cnf.GetString("order.notify.smtp.from", ""),
+				cnf.GetString("order.notify.smtp.username", ""),
+				cnf.GetString("order.notify.smtp.password", ""),
+			),
+			subject: cnf.GetString("nos.push.title", "InWeCrypto"),
+		}
+	}
+
+	token := cnf.GetString("order.notify.telegram.token", "")
+
+	if channels[channelTelegram] != (token != "") {
+		return nil, fmt.Errorf("order.notify.telegram.token must be set if and only if %s is in order.notify.channels", channelTelegram)
+	}
+
+	if token != "" {
+		notifiers[channelTelegram] = &telegramNotifier{
+			sender: notify.NewTelegramSender(
+				cnf.GetString("order.notify.telegram.api", "https://api.telegram.org"),
+				token,
+				cnf.GetDuration("order.notify.telegram.timeout", 10*time.Second),
+			),
+		}
+	}
+
+	return notifiers, nil
+}
+
+// validateChannel validate the notification channel and its target, an empty channel is push
+func validateChannel(notifiers map[string]notifier, channel string, target string) (string, error) {
+	if channel == "" || channel == channelPush {
+		if target != "" {
+			return "", newValidationError("target", "target must be empty for %s", channelPush)
+		}
+
+		return "", nil
+	}
+
+	if _, ok := notifiers[channel]; !ok {
+		names := make([]string, 0, len(notifiers))
+
+		for name := range notifiers {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return "", newValidationError("channel", "channel must be one of %s", strings.Join(names, ", "))
+	}
+
+	switch channel {
+	case channelEmail:
+		address, err := mail.ParseAddress(target)
+
+		if err != nil || address.Name != "" {
+			return "", newValidationError("target", "target must be an email address")
+		}
+	case channelTelegram:
+		if !telegramChat.MatchString(target) {
+			return "", newValidationError("target", "target must be a telegram chat id or @channel")
+		}
+	}
+
+	return channel, nil
+}
+
+// telegramChat a numeric chat id or a public @channel username
+var telegramChat = regexp.MustCompile(`^(-?[0-9]{1,20}|@[A-Za-z][A-Za-z0-9_]{4,31})$`)
+
+func (notifier *aliyunNotifier) notify(notification *Notification) error {
+	_, err := notifier.client.Push(&push.PushArgs{
+		AppKey:      notifier.appkey,
+		Target:      push.PushTargetAccount,
+		TargetValue: notification.UserID,
+		DeviceType:  push.PushDeviceTypeAll,
+		PushType:    push.PushTypeNotice,
+		Title:       notifier.title,
+		Body:        notification.Message,
+	})
+
+	return err
+}
+
+// queueNotification write the notification to the outbox within session, a notification whose
+// key was queued already for the user is dropped, tz is the database timezone
+func queueNotification(session *xorm.Session, tz *time.Location, notification *Notification) error {
+	notification.Status = notificationPending
+	notification.NextTime = time.Now()
+
+	if notification.Key == "" {
+		_, err := session.Insert(notification)
+
+		return err
+	}
+
+	now := formatDBTime(notification.NextTime, tz)
+
+	_, err := session.Exec(`INSERT INTO neo_notification
+  (user_i_d, channel, target, category, event, message, t_x, "key", status, attempts, error, next_time, create_time)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, '', ?, ?)
+ON CONFLICT (user_i_d, "key") WHERE "key" <> '' DO NOTHING`,
+		notification.UserID, notification.Channel, notification.Target, notification.Category, notification.Event,
+		notification.Message, notification.TX, notification.Key, notification.Status, now, now,
+	)
+
+	return err
+}
+
+// dispatcher poll the outbox and deliver the pending notifications
+type dispatcher struct {
+	slf4go.Logger
+	db          *xorm.Engine
+	notifiers   map[string]notifier
+	poll        time.Duration // outbox poll interval when idle
+	pace        time.Duration // min interval between two deliveries
+	batch       int
+	lease       time.Duration // a claimed notification is retried after lease if not marked
+	backoff     time.Duration // first retry delay, doubled on each attempt
+	maxAttempts int
+}
+
+func newDispatcher(cnf *config.Config, db *xorm.Engine) (*dispatcher, error) {
+	notifiers, err := newNotifiers(cnf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dispatcher{
+		Logger:      slf4go.Get("notification-dispatcher"),
+		db:          db,
+		notifiers:   notifiers,
+		poll:        cnf.GetDuration("order.outbox.poll", time.Second),
+		pace:        cnf.GetDuration("nos.push.duration", time.Second*2),
+		batch:       int(cnf.GetInt64("order.outbox.batch", 10)),
+		lease:       cnf.GetDuration("order.outbox.lease", 5*time.Minute),
+		backoff:     cnf.GetDuration("order.outbox.backoff", 30*time.Second),
+		maxAttempts: int(cnf.GetInt64("order.outbox.attempts", 10)),
+	}, nil
+}
+
+func (dispatcher *dispatcher) run() {
+	ticker := time.NewTicker(dispatcher.pace)
+	defer ticker.Stop()
+
+	for {
+		notifications, err := dispatcher.claim()
+
+		if err != nil {
+			dispatcher.ErrorF("claim notifications error, %s", err)
+		}
+
+		if len(notifications) == 0 {
+			time.Sleep(dispatcher.poll)
+			continue
+		}
+
+		for _, notification := range notifications {
+			<-ticker.C
+
+			dispatcher.deliver(notification)
+		}
+	}
+}
+
+// claim lease the next batch of due notifications, concurrent dispatchers skip
+// the notifications claimed by the others
+func (dispatcher *dispatcher) claim() ([]*Notification, error) {
+	now := time.Now()
+
+	var notifications []*Notification
+
+	err := dispatcher.db.SQL(`UPDATE neo_notification SET next_time = ? WHERE id IN (
+  SELECT id FROM neo_notification WHERE status = ? and next_time <= ?
+  ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED
+) RETURNING *`,
+		formatDBTime(now.Add(dispatcher.lease), dispatcher.db.DatabaseTZ),
+		notificationPending, formatDBTime(now, dispatcher.db.DatabaseTZ), dispatcher.batch,
+	).Find(&notifications)
+
+	return notifications, err
+}
+
+// deliver send notification and record the result, failures are retried with an exponential backoff
+func (dispatcher *dispatcher) deliver(notification *Notification) {
+	var err error
+
+	if notifier, ok := dispatcher.notifiers[notification.Channel]; ok {
+		err = notifier.notify(notification)
+	} else {
+		err = fmt.Errorf("unknown channel %s", notification.Channel)
+	}
+
+	if err != nil {
+		dispatcher.WarnF("deliver notification %d to %s error, %s", notification.ID, notification.UserID, err)
+	}
+
+	dispatcher.attempted(notification, err, time.Now())
+
+	_, err = dispatcher.db.ID(notification.ID).
+		Cols("status", "attempts", "error", "next_time", "deliver_time").
+		Update(notification)
+
+	if err != nil {
+		dispatcher.ErrorF("update notification %d error, %s", notification.ID, err)
+	}
+}
+
+// attempted record the result err of a delivery attempt of notification at now, a failed
+// notification is retried after backoff until maxAttempts
+func (dispatcher *dispatcher) attempted(notification *Notification, err error, now time.Time) {
+	notification.Attempts++
+
+	if err == nil {
+		notification.Status = notificationDelivered
+		notification.Error = ""
+		notification.DeliverTime = &now
+
+		return
+	}
+
+	notification.Error = err.Error()
+
+	if notification.Attempts >= dispatcher.maxAttempts {
+		notification.Status = notificationFailed
+	}
+
+	notification.NextTime = now.Add(dispatcher.retryDelay(notification.Attempts))
+}
+
+// retryDelay the delay before the retry of the delivery failed attempts times, doubled
+// on each attempt up to maxNotificationBackoff
+func (dispatcher *dispatcher) retryDelay(attempts int) time.Duration {
+	backoff := dispatcher.backoff << uint(attempts-1)
+
+	if backoff <= 0 || backoff > maxNotificationBackoff || attempts > 63 {
+		return maxNotificationBackoff
+	}
+
+	return backoff
+}
+
+// NotificationPage one page of the notification history api
+type NotificationPage struct {
+	Notifications []*Notification `json:"notifications"`
+	Next          string          `json:"next,omitempty"` // cursor of the next page, empty on the last page
+}
+
+// listNotifications the notifications of userid newest first, before the cursor id if not zero
+func (service *HTTPServer) listNotifications(userid string, status string, category string, cursor int64, limit int) (*NotificationPage, error) {
+	session := service.db.Where("user_i_d = ?", userid)
+
+	if status != "" {
+		session = session.And("status = ?", status)
+	}
+
+	if category != "" {
+		session = session.And("category = ?", category)
+	}
+
+	if cursor > 0 {
+		session = session.And("id < ?", cursor)
+	}
+
+	page := &NotificationPage{
+		Notifications: make([]*Notification, 0),
+	}
+
+	if err := session.Desc("id").Limit(limit + 1).Find(&page.Notifications); err != nil {
+		return nil, err
+	}
+
+	if len(page.Notifications) > limit {
+		page.Notifications = page.Notifications[:limit]
+		page.Next = fmt.Sprintf("%d", page.Notifications[limit-1].ID)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,192 @@
+package orderservice
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB connect to the scratch postgres database of NEO_ORDER_TEST_DB, a lib/pq
+// connection string, the test is skipped if it is not set
+func newTestDB(t *testing.T) *xorm.Engine {
+	source := os.Getenv("NEO_ORDER_TEST_DB")
+
+	if source == "" {
+		t.Skip("NEO_ORDER_TEST_DB not set")
+	}
+
+	db, err := xorm.NewEngine("postgres", source)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestDispatcherRetryDelay(t *testing.T) {
+	dispatcher := &dispatcher{backoff: 30 * time.Second}
+
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, maxNotificationBackoff},
+		{64, maxNotificationBackoff},
+		{1000, maxNotificationBackoff},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.delay, dispatcher.retryDelay(test.attempts), "attempts %d", test.attempts)
+	}
+}
+
+func TestDispatcherAttempted(t *testing.T) {
+	dispatcher := &dispatcher{backoff: time.Second, maxAttempts: 3}
+	notification := &Notification{Status: notificationPending}
+	now := time.Now()
+	failure := errors.New("smtp unavailable")
+
+	dispatcher.attempted(notification, failure, now)
+
+	assert.Equal(t, notificationPending, notification.Status)
+	assert.Equal(t, 1, notification.Attempts)
+	assert.Equal(t, "smtp unavailable", notification.Error)
+	assert.Equal(t, now.Add(time.Second), notification.NextTime)
+
+	dispatcher.attempted(notification, failure, now)
+
+	assert.Equal(t, notificationPending, notification.Status)
+	assert.Equal(t, now.Add(2*time.Second), notification.NextTime)
+
+	dispatcher.attempted(notification, failure, now)
+
+	assert.Equal(t, notificationFailed, notification.Status)
+	assert.Equal(t, 3, notification.Attempts)
+
+	delivered := &Notification{Status: notificationPending, Attempts: 1, Error: "timeout"}
+
+	dispatcher.attempted(delivered, nil, now)
+
+	assert.Equal(t, notificationDelivered, delivered.Status)
+	assert.Equal(t, 2, delivered.Attempts)
+	assert.Empty(t, delivered.Error)
+
+	if assert.NotNil(t, delivered.DeliverTime) {
+		assert.Equal(t, now, *delivered.DeliverTime)
+	}
+}
+
+func TestDispatcherClaim(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.Sync2(new(Notification)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS neo_notification_key ON neo_notification ("user_i_d", "key") WHERE "key" <> ''`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("DELETE FROM neo_notification"); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := func(notification *Notification) {
+		session := db.NewSession()
+		defer session.Close()
+
+		if err := queueNotification(session, db.DatabaseTZ, notification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, key := range []string{"received|tx1", "received|tx2", "received|tx3", "received|tx1", ""} {
+		queue(&Notification{UserID: "claim-test", Channel: channelPush, Event: "received", Message: "m", Key: key})
+	}
+
+	count, err := db.Where("user_i_d = ?", "claim-test").Count(new(Notification))
+
+	if assert.NoError(t, err) {
+		// the duplicated key is dropped
+		assert.Equal(t, int64(4), count)
+	}
+
+	_, err = db.Insert(&Notification{
+		UserID: "claim-test", Channel: channelPush, Event: "received", Message: "future",
+		Status: notificationPending, NextTime: time.Now().Add(time.Hour),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := &dispatcher{db: db, batch: 3, lease: 5 * time.Minute}
+
+	first, err := dispatcher.claim()
+
+	if assert.NoError(t, err) {
+		assert.Len(t, first, 3)
+
+		for _, notification := range first {
+			assert.True(t, notification.NextTime.After(time.Now().Add(4*time.Minute)), "leased")
+		}
+	}
+
+	second, err := dispatcher.claim()
+
+	if assert.NoError(t, err) {
+		// the claimed notifications are leased, the future one is not due
+		assert.Len(t, second, 1)
+	}
+
+	third, err := dispatcher.claim()
+
+	if assert.NoError(t, err) {
+		assert.Len(t, third, 0)
+	}
+}
+
+func TestNewNotifiersChannels(t *testing.T) {
+	tests := []struct {
+		source   string
+		channels []string
+		fails    bool
+	}{
+		{`{}`, []string{channelPush}, false},
+		{`{"order": {"notify": {"channels": ["push", "email"], "smtp": {"addr": "smtp.example.com:587"}}}}`, []string{channelPush, channelEmail}, false},
+		{`{"order": {"notify": {"channels": ["push", "telegram"], "telegram": {"token": "t"}}}}`, []string{channelPush, channelTelegram}, false},
+		{`{"order": {"notify": {"smtp": {"addr": "smtp.example.com:587"}}}}`, nil, true},
+		{`{"order": {"notify": {"channels": ["push", "email"]}}}`, nil, true},
+		{`{"order": {"notify": {"channels": ["push", "telegram"]}}}`, nil, true},
+		{`{"order": {"notify": {"channels": ["push", "sms"]}}}`, nil, true},
+	}
+
+	for _, test := range tests {
+		notifiers, err := newNotifiers(newTestConfig(t, test.source))
+
+		if test.fails {
+			assert.Error(t, err, test.source)
+			continue
+		}
+
+		if assert.NoError(t, err, test.source) {
+			assert.Len(t, notifiers, len(test.channels), test.source)
+
+			for _, channel := range test.channels {
+				assert.Contains(t, notifiers, channel, test.source)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package price fiat price sources of the NEO assets
+package price
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Source quote the current prices of assets, identified by symbol, in a fiat currency.
+// Symbols without a quote are left out of the result.
+type Source interface {
+	Prices(currency string, symbols []string) (map[string]string, error)
+}
+
+// FileSource prices read from a JSON file mapping currency to symbol to price,
+// e.g. {"USD": {"NEO": "50.12", "GAS": "15.3"}}, the file is read on every call
+// so it can be updated while the service runs
+type FileSource struct {
+	path string
+}
+
+// NewFileSource create a file source reading path
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Prices implement Source
+func (source *FileSource) Prices(currency string, symbols []string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(source.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var currencies map[string]map[string]json.Number
+
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return nil, fmt.Errorf("price file %s error, %s", source.path, err)
+	}
+
+	return pick(currencies[currency], symbols)
+}
+
+// HTTPSource prices queried from an HTTP endpoint as GET url?currency=USD&symbols=NEO,GAS
+// answering a JSON object mapping symbol to price, e.g. {"NEO": 50.12, "GAS": "15.3"}
+type HTTPSource struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSource create a http source querying endpoint with timeout
+func NewHTTPSource(endpoint string, timeout time.Duration) *HTTPSource {
+	return &HTTPSource{
+		url:    endpoint,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Prices implement Source
+func (source *HTTPSource) Prices(currency string, symbols []string) (map[string]string, error) {
+	endpoint, err := url.Parse(source.url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := endpoint.Query()
+	query.Set("currency", currency)
+	query.Set("symbols", strings.Join(symbols, ","))
+	endpoint.RawQuery = query.Encode()
+
+	resp, err := source.client.Get(endpoint.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price source %s status %d", source.url, resp.StatusCode)
+	}
+
+	var prices map[string]json.Number
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&prices); err != nil {
+		return nil, fmt.Errorf("price source %s error, %s", source.url, err)
+	}
+
+	return pick(prices, symbols)
+}
+
+// pick the validated prices of symbols
+func pick(prices map[string]json.Number, symbols []string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	for _, symbol := range symbols {
+		price, ok := prices[symbol]
+
+		if !ok {
+			continue
+		}
+
+		value, ok := new(big.Rat).SetString(price.String())
+
+		if !ok || value.Sign() < 0 {
+			return nil, fmt.Errorf("invalid %s price %q", symbol, price)
+		}
+
+		result[symbol] = price.String()
+	}
+
+	return result, nil
+}
+
+// Value the fiat value of amount at price, rounded half up to decimals
+func Value(amount string, price string, decimals int) (string, error) {
+	a, ok := new(big.Rat).SetString(amount)
+
+	if !ok {
+		return "", fmt.Errorf("invalid amount %q", amount)
+	}
+
+	p, ok := new(big.Rat).SetString(price)
+
+	if !ok {
+		return "", fmt.Errorf("invalid price %q", price)
+	}
+
+	return a.Mul(a, p).FloatString(decimals), nil
+}
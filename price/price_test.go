@@ -0,0 +1,98 @@
+package price
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilePriceSource(t *testing.T) {
+	file, err := ioutil.TempFile("", "prices")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(`{"USD": {"NEO": "50.12", "GAS": 15.3}, "CNY": {"NEO": "330"}}`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	prices, err := NewFileSource(file.Name()).Prices("USD", []string{"NEO", "GAS", "RPX"})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"NEO": "50.12", "GAS": "15.3"}, prices)
+	}
+
+	prices, err = NewFileSource(file.Name()).Prices("EUR", []string{"NEO"})
+
+	if assert.NoError(t, err) {
+		assert.Empty(t, prices)
+	}
+}
+
+func TestHTTPPriceSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("currency") != "USD" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		assert.Equal(t, "NEO,GAS", r.URL.Query().Get("symbols"))
+
+		w.Write([]byte(`{"NEO": 50.12, "GAS": "15.3"}`))
+	}))
+
+	defer server.Close()
+
+	source := NewHTTPSource(server.URL, time.Second)
+
+	prices, err := source.Prices("USD", []string{"NEO", "GAS"})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"NEO": "50.12", "GAS": "15.3"}, prices)
+	}
+
+	_, err = source.Prices("EUR", []string{"NEO", "GAS"})
+	assert.Error(t, err)
+}
+
+func TestHTTPPriceSourceInvalidPrice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"NEO": "-1"}`))
+	}))
+
+	defer server.Close()
+
+	_, err := NewHTTPSource(server.URL, time.Second).Prices("USD", []string{"NEO"})
+	assert.Error(t, err)
+}
+
+func TestFiatValue(t *testing.T) {
+	value, err := Value("10", "50.125", 2)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "501.25", value)
+	}
+
+	value, err = Value("0.00000001", "15.3", 2)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.00", value)
+	}
+
+	value, err = Value("1.5", "0.005", 2)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.01", value)
+	}
+
+	_, err = Value("abc", "1", 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,344 @@
+package orderservice
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/dynamicgo/config"
+	"github.com/go-xorm/xorm"
+)
+
+// push events
+const (
+	pushReceived  = "received"  // confirmed transfer to a wallet
+	pushSent      = "sent"      // confirmed transfer from a wallet not created through the api
+	pushConfirmed = "confirmed" // order created through the api confirmed
+	pushPaid      = "paid"      // invoice paid, partially paid or overpaid
+	pushExpired   = "expired"   // invoice expired unpaid
+	pushContract  = "contract"  // confirmed transfer of a subscribed contract
+
+	pushAlertLarge        = "alert_large"        // large transfer alert rule fired
+	pushAlertCounterparty = "alert_counterparty" // new counterparty alert rule fired
+	pushAlertOutgoing     = "alert_outgoing"     // outgoing transfer alert rule fired
+)
+
+var pushEvents = []string{
+	pushReceived, pushSent, pushConfirmed, pushPaid, pushExpired, pushContract,
+	pushAlertLarge, pushAlertCounterparty, pushAlertOutgoing,
+}
+
+// alertEvents the push event of each alert rule kind
+var alertEvents = map[string]string{
+	alertLarge:        pushAlertLarge,
+	alertCounterparty: pushAlertCounterparty,
+	alertOutgoing:     pushAlertOutgoing,
+}
+
+// notification categories
+const (
+	categoryOrder   = "order"
+	categoryInvoice = "invoice"
+	categoryAlert   = "alert"
+)
+
+// pushCategories the notification category of each push event
+var pushCategories = map[string]string{
+	pushReceived:          categoryOrder,
+	pushSent:              categoryOrder,
+	pushConfirmed:         categoryOrder,
+	pushPaid:              categoryInvoice,
+	pushExpired:           categoryInvoice,
+	pushContract:          categoryOrder,
+	pushAlertLarge:        categoryAlert,
+	pushAlertCounterparty: categoryAlert,
+	pushAlertOutgoing:     categoryAlert,
+}
+
+// defaultPushTemplates builtin text/template push messages by locale and event
+var defaultPushTemplates = map[string]map[string]string{
+	"en-US": {
+		pushReceived:  `received {{.Value}} {{.AssetName}}{{if .Label}} from {{.Label}}{{end}}, tx {{.TX}}`,
+		pushSent:      `sent {{.Value}} {{.AssetName}}{{if .Label}} to {{.Label}}{{end}}, tx {{.TX}}`,
+		pushConfirmed: `your transfer of {{.Value}} {{.AssetName}}{{if .Label}} to {{.Label}}{{end}} is confirmed, tx {{.TX}}`,
+		pushPaid:      `invoice {{.InvoiceID}} {{.InvoiceStatus}}, received {{.Paid}} of {{.Amount}} {{.AssetName}}`,
+		pushExpired:   `invoice {{.InvoiceID}} expired, received {{.Paid}} of {{.Amount}} {{.AssetName}}`,
+		pushContract:  `{{.AssetName}} transfer of {{.Value}} from {{.Address}} to {{if .Label}}{{.Label}}{{else}}{{.Counterparty}}{{end}}, tx {{.TX}}`,
+
+		pushAlertLarge:        `alert: large transfer of {{.Value}} {{.AssetName}} (threshold {{.Threshold}}) on {{.Address}}, tx {{.TX}}`,
+		pushAlertCounterparty: `alert: {{.Address}} sent {{.Value}} {{.AssetName}} to new address {{if .Label}}{{.Label}}{{else}}{{.Counterparty}}{{end}}, tx {{.TX}}`,
+		pushAlertOutgoing:     `alert: watched address {{.Address}} sent {{.Value}} {{.AssetName}} to {{if .Label}}{{.Label}}{{else}}{{.Counterparty}}{{end}}, tx {{.TX}}`,
+	},
+	"zh-CN": {
+		pushReceived:  `收到 {{.Value}} {{.AssetName}}{{if .Label}}，来自 {{.Label}}{{end}}，交易 {{.TX}}`,
+		pushSent:      `转出 {{.Value}} {{.AssetName}}{{if .Label}} 至 {{.Label}}{{end}}，交易 {{.TX}}`,
+		pushConfirmed: `您转出的 {{.Value}} {{.AssetName}}{{if .Label}}（至 {{.Label}}）{{end}}已确认，交易 {{.TX}}`,
+		pushPaid:      `收款单 {{.InvoiceID}} {{if eq .InvoiceStatus "partial"}}部分支付{{else if eq .InvoiceStatus "overpaid"}}超额支付{{else}}已支付{{end}}，已收到 {{.Paid}} / {{.Amount}} {{.AssetName}}`,
+		pushExpired:   `收款单 {{.InvoiceID}} 已过期，已收到 {{.Paid}} / {{.Amount}} {{.AssetName}}`,
+		pushContract:  `{{.AssetName}} 合约转账 {{.Value}}，从 {{.Address}} 至 {{if .Label}}{{.Label}}{{else}}{{.Counterparty}}{{end}}，交易 {{.TX}}`,
+
+		pushAlertLarge:        `警报：{{.Address}} 大额转账 {{.Value}} {{.AssetName}}（阈值 {{.Threshold}}），交易 {{.TX}}`,
+		pushAlertCounterparty: `警报：{{.Address}} 向新地址 {{if .Label}}{{.Label}}{{else}}{{.Counterparty}}{{end}} 转出 {{.Value}} {{.AssetName}}，交易 {{.TX}}`,
+		pushAlertOutgoing:     `警报：监控地址 {{.Address}} 转出 {{.Value}} {{.AssetName}} 至 {{if .Label}}{{.Label}}{{else}}{{.Counterparty}}{{end}}，交易 {{.TX}}`,
+	},
+}
+
+// PushData the fields available to the push templates, the invoice fields are set
+// for the invoice events only
+type PushData struct {
+	Event         string
+	Address       string // the wallet or invoice address
+	Counterparty  string
+	Label         string // label of the counterparty seen by the user, if any
+	Asset         string
+	AssetName     string
+	Value         string
+	TX            string
+	InvoiceID     string
+	InvoiceStatus string
+	Amount        string
+	Paid          string
+	Memo          string
+	Threshold     string // the threshold of the large transfer alert rule
+}
+
+// samplePushData the data rendered by the preview api
+var samplePushData = map[string]*PushData{
+	pushReceived: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Counterparty: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", Label: "Alice",
+		Asset: neoAsset, AssetName: "NEO", Value: "10",
+		TX: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+	},
+	pushSent: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Counterparty: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", Label: "Alice",
+		Asset: gasAsset, AssetName: "GAS", Value: "1.5",
+		TX: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+	},
+	pushConfirmed: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Counterparty: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", Label: "Alice",
+		Asset: neoAsset, AssetName: "NEO", Value: "10",
+		TX: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+	},
+	pushPaid: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Asset: gasAsset, AssetName: "GAS",
+		InvoiceID: "4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a99", InvoiceStatus: invoicePartial, Amount: "10", Paid: "4", Memo: "order-1",
+	},
+	pushExpired: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Asset: gasAsset, AssetName: "GAS",
+		InvoiceID: "4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a99", InvoiceStatus: invoiceExpired, Amount: "10", Paid: "0", Memo: "order-1",
+	},
+	pushContract: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Counterparty: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", Label: "Alice",
+		Asset: rpxAsset, AssetName: "RPX", Value: "100",
+		TX: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+	},
+	pushAlertLarge: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Counterparty: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", Label: "Alice",
+		Asset: neoAsset, AssetName: "NEO", Value: "1000", Threshold: "500",
+		TX: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+	},
+	pushAlertCounterparty: {
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Counterparty: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y",
+		Asset: gasAsset, AssetName: "GAS", Value: "1.5",
+		TX: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+	},
+	pushAlertOutgoing: {
+		Address: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", Counterparty: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		Asset: neoAsset, AssetName: "NEO", Value: "10",
+		TX: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+	},
+}
+
+// pushTemplates the push message templates of every locale
+type pushTemplates struct {
+	locales  map[string]*template.Template
+	fallback string
+}
+
+// newPushTemplates parse the builtin templates, overridden or extended by the
+// order.push.templates directory holding <locale>/<event>.tmpl files
+func newPushTemplates(cnf *config.Config) (*pushTemplates, error) {
+	sources := make(map[string]map[string]string)
+
+	for locale, events := range defaultPushTemplates {
+		sources[locale] = make(map[string]string)
+
+		for event, text := range events {
+			sources[locale][event] = text
+		}
+	}
+
+	if dir := cnf.GetString("order.push.templates", ""); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*", "*.tmpl"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			locale := filepath.Base(filepath.Dir(file))
+			event := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+
+			data, err := ioutil.ReadFile(file)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if sources[locale] == nil {
+				sources[locale] = make(map[string]string)
+			}
+
+			sources[locale][event] = strings.TrimRight(string(data), "\r\n")
+		}
+	}
+
+	templates := &pushTemplates{
+		locales:  make(map[string]*template.Template),
+		fallback: cnf.GetString("order.push.locale", "en-US"),
+	}
+
+	if _, ok := sources[templates.fallback]; !ok {
+		return nil, fmt.Errorf("push locale %s has no templates", templates.fallback)
+	}
+
+	for locale, events := range sources {
+		root := template.New(locale).Option("missingkey=error")
+
+		for event, text := range events {
+			if _, err := root.New(event).Parse(text); err != nil {
+				return nil, fmt.Errorf("push template %s/%s error, %s", locale, event, err)
+			}
+		}
+
+		templates.locales[locale] = root
+	}
+
+	for _, event := range pushEvents {
+		if templates.locales[templates.fallback].Lookup(event) == nil {
+			return nil, fmt.Errorf("push locale %s has no %s template", templates.fallback, event)
+		}
+	}
+
+	return templates, nil
+}
+
+// list the supported locales
+func (templates *pushTemplates) list() []string {
+	locales := make([]string, 0, len(templates.locales))
+
+	for locale := range templates.locales {
+		locales = append(locales, locale)
+	}
+
+	sort.Strings(locales)
+
+	return locales
+}
+
+// validateLocale return the supported locale matching value case insensitively
+func (templates *pushTemplates) validateLocale(field string, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	for locale := range templates.locales {
+		if strings.EqualFold(locale, value) {
+			return locale, nil
+		}
+	}
+
+	return "", newValidationError(field, "%s must be one of %s", field, strings.Join(templates.list(), ", "))
+}
+
+// render the message of event in locale, falling back to the default locale for
+// unknown locales and events the locale has no template of
+func (templates *pushTemplates) render(locale string, event string, data *PushData) (string, error) {
+	root, ok := templates.locales[locale]
+
+	if !ok || root.Lookup(event) == nil {
+		root = templates.locales[templates.fallback]
+	}
+
+	data.Event = event
+
+	var buff bytes.Buffer
+
+	if err := root.ExecuteTemplate(&buff, event, data); err != nil {
+		return "", err
+	}
+
+	return buff.String(), nil
+}
+
+// userPreferences the locale and notification channel the user chose for address, else
+// for any of the user wallets, an empty channel is aliyun push
+func userPreferences(db *xorm.Engine, userid string, address string) (*WalletSettings, error) {
+	var settings []*WalletSettings
+
+	err := db.Where("user_i_d = ? and (locale <> '' or channel <> '')", userid).Asc("id").Find(&settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the settings of address come first
+	sort.SliceStable(settings, func(i, j int) bool {
+		return settings[i].Address == address && settings[j].Address != address
+	})
+
+	preferences := new(WalletSettings)
+
+	for _, setting := range settings {
+		if preferences.Locale == "" {
+			preferences.Locale = setting.Locale
+		}
+
+		if preferences.Channel == "" {
+			preferences.Channel = setting.Channel
+			preferences.Target = setting.Target
+		}
+	}
+
+	return preferences, nil
+}
+
+// pushPreview one rendered push message of the preview api
+type pushPreview struct {
+	Locale  string `json:"locale"`
+	Event   string `json:"event"`
+	Message string `json:"message"`
+}
+
+// preview render the sample messages of locale, of event only if not empty
+func (templates *pushTemplates) preview(locale string, event string) ([]*pushPreview, error) {
+	events := pushEvents
+
+	if event != "" {
+		if _, ok := samplePushData[event]; !ok {
+			return nil, newValidationError("event", "event must be one of %s", strings.Join(pushEvents, ", "))
+		}
+
+		events = []string{event}
+	}
+
+	previews := make([]*pushPreview, 0, len(events))
+
+	for _, event := range events {
+		data := *samplePushData[event]
+
+		message, err := templates.render(locale, event, &data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		previews = append(previews, &pushPreview{Locale: locale, Event: event, Message: message})
+	}
+
+	return previews, nil
+}
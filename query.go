@@ -0,0 +1,281 @@
+package orderservice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neodb"
+)
+
+// order status filter values
+const (
+	orderStatusConfirmed = "confirmed"
+	orderStatusPending   = "pending"
+)
+
+// order direction filter values, relative to the queried address
+const (
+	orderDirectionIn  = "in"
+	orderDirectionOut = "out"
+)
+
+// OrderPage one page of the orders list api
+type OrderPage struct {
+	Orders []*Order `json:"orders"`
+	Next   string   `json:"next,omitempty"`  // cursor of the next page, empty on the last page
+	Total  *int64   `json:"total,omitempty"` // total orders matching the filters, only when count=true
+}
+
+// orderCursor opaque keyset cursor on (create_time, id)
+type orderCursor struct {
+	CreateTime time.Time `json:"t"`
+	ID         int64     `json:"i"`
+}
+
+func (cursor *orderCursor) String() string {
+	data, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseOrderCursor(value string) (*orderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cursor orderCursor
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &cursor, nil
+}
+
+// orderQuery filters of the orders list api
+type orderQuery struct {
+	Address   string
+	Assets    []string // empty for all assets
+	Status    string
+	Direction string
+	Since     *time.Time
+	Until     *time.Time
+	Context   map[string]string // context JSON path to value
+	View      string
+	Cursor    *orderCursor
+	Limit     int
+	Count     bool
+}
+
+func (service *HTTPServer) parseOrderQuery(ctx *gin.Context) (*orderQuery, error) {
+	query := &orderQuery{
+		Status:    ctx.Query("status"),
+		Direction: ctx.Query("direction"),
+		View:      ctx.DefaultQuery("view", orderViewOrder),
+		Limit:     service.defaultPageLimit,
+	}
+
+	var err error
+
+	if query.Address, err = validateAddress("address", ctx.Query("address")); err != nil {
+		return nil, err
+	}
+
+	if query.Assets, err = service.validateAssets("asset", parseAssets(ctx.Query("asset"))); err != nil {
+		return nil, err
+	}
+
+	switch query.Status {
+	case "", orderStatusConfirmed, orderStatusPending:
+	default:
+		return nil, newValidationError("status", "status must be %s or %s", orderStatusConfirmed, orderStatusPending)
+	}
+
+	switch query.Direction {
+	case "", orderDirectionIn, orderDirectionOut:
+	default:
+		return nil, newValidationError("direction", "direction must be %s or %s", orderDirectionIn, orderDirectionOut)
+	}
+
+	switch query.View {
+	case orderViewOrder, orderViewTx:
+	default:
+		return nil, newValidationError("view", "view must be %s or %s", orderViewOrder, orderViewTx)
+	}
+
+	if query.Since, err = parseTimeQuery(ctx, "since"); err != nil {
+		return nil, err
+	}
+
+	if query.Until, err = parseTimeQuery(ctx, "until"); err != nil {
+		return nil, err
+	}
+
+	if query.Context, err = parseContextFilters(ctx.Request.URL.Query()); err != nil {
+		return nil, err
+	}
+
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		if query.Cursor, err = parseOrderCursor(cursor); err != nil {
+			return nil, newValidationError("cursor", "%s", err)
+		}
+	}
+
+	if limit := ctx.Query("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+
+		if err != nil || query.Limit <= 0 {
+			return nil, newValidationError("limit", "limit must be a positive integer")
+		}
+	}
+
+	if query.Limit > service.maxPageLimit {
+		query.Limit = service.maxPageLimit
+	}
+
+	if count := ctx.Query("count"); count != "" {
+		if query.Count, err = strconv.ParseBool(count); err != nil {
+			return nil, newValidationError("count", "count must be a boolean")
+		}
+	}
+
+	return query, nil
+}
+
+// parseAssets parse comma-separated asset ids, empty or "all" means all assets
+func parseAssets(value string) []string {
+	assets := make([]string, 0)
+
+	for _, asset := range strings.Split(value, ",") {
+		asset = strings.TrimSpace(asset)
+
+		if asset == "" || asset == "all" {
+			continue
+		}
+
+		assets = append(assets, asset)
+	}
+
+	return assets
+}
+
+func parseTimeQuery(ctx *gin.Context, name string) (*time.Time, error) {
+	value := ctx.Query(name)
+
+	if value == "" {
+		return nil, nil
+	}
+
+	result, err := time.Parse(time.RFC3339Nano, value)
+
+	if err != nil {
+		return nil, newValidationError(name, "%s must be RFC3339 time", name)
+	}
+
+	return &result, nil
+}
+
+// filter apply the query filters except the cursor to session
+func (query *orderQuery) filter(session *xorm.Session, tz *time.Location) *xorm.Session {
+	switch query.Direction {
+	case orderDirectionIn:
+		session = session.Where(`"to" = ?`, query.Address)
+	case orderDirectionOut:
+		session = session.Where(`"from" = ?`, query.Address)
+	default:
+		session = session.Where(`("from" = ? or "to" = ?)`, query.Address, query.Address)
+	}
+
+	if len(query.Assets) > 0 {
+		session = session.In("asset", query.Assets)
+	}
+
+	switch query.Status {
+	case orderStatusConfirmed:
+		session = session.And("confirm_time is not null")
+	case orderStatusPending:
+		session = session.And("confirm_time is null")
+	}
+
+	if query.Since != nil {
+		session = session.And("create_time >= ?", formatDBTime(*query.Since, tz))
+	}
+
+	if query.Until != nil {
+		session = session.And("create_time < ?", formatDBTime(*query.Until, tz))
+	}
+
+	return filterContext(session, query.Context)
+}
+
+// formatDBTime format t as a TIMESTAMP literal in the database timezone
+func formatDBTime(t time.Time, tz *time.Location) string {
+	return t.In(tz).Format("2006-01-02 15:04:05.999999")
+}
+
+func (service *HTTPServer) queryOrders(query *orderQuery) (*OrderPage, error) {
+
+	service.DebugF("query orders %+v", query)
+
+	session := query.filter(service.db.NewSession(), service.db.DatabaseTZ)
+
+	defer session.Close()
+
+	if query.Cursor != nil {
+		createTime := formatDBTime(query.Cursor.CreateTime, service.db.DatabaseTZ)
+
+		session = session.And(
+			"(create_time < ? or (create_time = ? and id < ?))",
+			createTime, createTime, query.Cursor.ID,
+		)
+	}
+
+	torders := make([]*neodb.Order, 0)
+
+	err := session.
+		Desc("create_time", "id").
+		Limit(query.Limit + 1).
+		Find(&torders)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrderPage{}
+
+	if len(torders) > query.Limit {
+		torders = torders[:query.Limit]
+
+		last := torders[len(torders)-1]
+
+		page.Next = (&orderCursor{CreateTime: last.CreateTime, ID: last.ID}).String()
+	}
+
+	if page.Orders, err = service.newOrders(torders); err != nil {
+		return nil, err
+	}
+
+	if query.Count {
+		countSession := query.filter(service.db.NewSession(), service.db.DatabaseTZ)
+
+		defer countSession.Close()
+
+		total, err := countSession.Count(new(neodb.Order))
+
+		if err != nil {
+			return nil, err
+		}
+
+		page.Total = &total
+	}
+
+	return page, nil
+}
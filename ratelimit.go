@@ -0,0 +1,426 @@
+package orderservice
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dynamicgo/config"
+	"github.com/dynamicgo/slf4go"
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+)
+
+// rateLimit token bucket parameters
+type rateLimit struct {
+	Rate  float64 `json:"rate"`  // tokens refilled per second
+	Burst float64 `json:"burst"` // bucket capacity
+}
+
+// take refill the bucket to now and try to take one token, returns the tokens left and
+// how long to wait for the next token when the bucket is empty
+func (limit *rateLimit) take(tokens float64, updateTime time.Time, now time.Time) (float64, time.Duration) {
+	if elapsed := now.Sub(updateTime).Seconds(); elapsed > 0 {
+		tokens = math.Min(limit.Burst, tokens+elapsed*limit.Rate)
+	}
+
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+
+	if limit.Rate <= 0 {
+		return tokens, time.Hour
+	}
+
+	return tokens, time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+}
+
+// rateLimitStore token bucket storage
+type rateLimitStore interface {
+	// take try to take one token from the bucket of key, returns the wait duration when empty
+	take(key string, limit *rateLimit, now time.Time) (time.Duration, error)
+	// purge drop the buckets idle longer than idle, they are full again anyway
+	purge(idle time.Duration) error
+}
+
+type tokenBucket struct {
+	tokens     float64
+	updateTime time.Time
+}
+
+// memoryRateLimitStore process local buckets, for single replica deployments
+type memoryRateLimitStore struct {
+	sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (store *memoryRateLimitStore) take(key string, limit *rateLimit, now time.Time) (time.Duration, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	bucket, ok := store.buckets[key]
+
+	if !ok {
+		bucket = &tokenBucket{tokens: limit.Burst, updateTime: now}
+		store.buckets[key] = bucket
+	}
+
+	var wait time.Duration
+
+	bucket.tokens, wait = limit.take(bucket.tokens, bucket.updateTime, now)
+	bucket.updateTime = now
+
+	return wait, nil
+}
+
+func (store *memoryRateLimitStore) purge(idle time.Duration) error {
+	store.Lock()
+	defer store.Unlock()
+
+	for key, bucket := range store.buckets {
+		if time.Since(bucket.updateTime) > idle {
+			delete(store.buckets, key)
+		}
+	}
+
+	return nil
+}
+
+// RateLimitBucket token bucket shared by replicas
+type RateLimitBucket struct {
+	ID         int64     `xorm:"pk autoincr"`
+	Key        string    `xorm:"notnull unique"`
+	Tokens     float64   `xorm:"notnull"`
+	UpdateTime time.Time `xorm:"TIMESTAMP notnull"`
+}
+
+// TableName xorm table name
+func (table *RateLimitBucket) TableName() string {
+	return "neo_rate_limit"
+}
+
+// postgresRateLimitStore buckets stored in postgres, for multi-replica deployments
+type postgresRateLimitStore struct {
+	db *xorm.Engine
+}
+
+func (store *postgresRateLimitStore) take(key string, limit *rateLimit, now time.Time) (wait time.Duration, err error) {
+	session := store.db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
+
+	_, err = session.Exec(
+		`INSERT INTO neo_rate_limit ("key", tokens, update_time) VALUES (?, ?, ?) ON CONFLICT ("key") DO NOTHING`,
+		key, limit.Burst, formatDBTime(now, store.db.DatabaseTZ),
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	bucket := new(RateLimitBucket)
+
+	ok, err := session.Where(`"key" = ?`, key).ForUpdate().Get(bucket)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return 0, fmt.Errorf("rate limit bucket %s not found", key)
+	}
+
+	bucket.Tokens, wait = limit.take(bucket.Tokens, bucket.UpdateTime, now)
+	bucket.UpdateTime = now
+
+	if _, err = session.ID(bucket.ID).Cols("tokens", "update_time").Update(bucket); err != nil {
+		return 0, err
+	}
+
+	return wait, session.Commit()
+}
+
+// purge every replica purges, the delete is idempotent
+func (store *postgresRateLimitStore) purge(idle time.Duration) error {
+	_, err := store.db.Exec(
+		`DELETE FROM neo_rate_limit WHERE update_time < ?`,
+		formatDBTime(time.Now().Add(-idle), store.db.DatabaseTZ),
+	)
+
+	return err
+}
+
+// rateLimiter per route token bucket rate limiter keyed by authenticated user or client ip
+type rateLimiter struct {
+	slf4go.Logger
+	enabled      bool
+	failOpen     bool // let the requests pass when the store fails
+	store        rateLimitStore
+	ipLimit      *rateLimit // all the requests of a client ip, checked before authentication
+	defaultLimit *rateLimit
+	routeLimits  map[string]*rateLimit
+	proxies      []*net.IPNet // reverse proxies trusted to set X-Forwarded-For
+}
+
+func newRateLimiter(cnf *config.Config, db *xorm.Engine) (*rateLimiter, error) {
+	limiter := &rateLimiter{
+		Logger:       slf4go.Get("ratelimit"),
+		enabled:      cnf.GetBool("order.ratelimit.enabled", true),
+		failOpen:     cnf.GetBool("order.ratelimit.failopen", true),
+		ipLimit:      &rateLimit{Rate: 20, Burst: 100},
+		defaultLimit: &rateLimit{Rate: 10, Burst: 50},
+		routeLimits: map[string]*rateLimit{
+			"POST /order":           {Rate: 1, Burst: 10},
+			"POST /channel/:userid": {Rate: 0.02, Burst: 3}, // each request sends a message to the target
+		},
+	}
+
+	if cnf.Has("order.ratelimit.ip") {
+		if err := cnf.GetObject("order.ratelimit.ip", limiter.ipLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	if cnf.Has("order.ratelimit.default") {
+		if err := cnf.GetObject("order.ratelimit.default", limiter.defaultLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	if cnf.Has("order.ratelimit.routes") {
+		var routes map[string]*rateLimit
+
+		if err := cnf.GetObject("order.ratelimit.routes", &routes); err != nil {
+			return nil, err
+		}
+
+		for route, limit := range routes {
+			limiter.routeLimits[route] = limit
+		}
+	}
+
+	if cnf.Has("order.ratelimit.proxies") {
+		var proxies []string
+
+		if err := cnf.GetObject("order.ratelimit.proxies", &proxies); err != nil {
+			return nil, err
+		}
+
+		for _, proxy := range proxies {
+			if !strings.Contains(proxy, "/") {
+				if strings.Contains(proxy, ":") {
+					proxy += "/128"
+				} else {
+					proxy += "/32"
+				}
+			}
+
+			_, network, err := net.ParseCIDR(proxy)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid rate limit proxy %s, %s", proxy, err)
+			}
+
+			limiter.proxies = append(limiter.proxies, network)
+		}
+	}
+
+	switch store := cnf.GetString("order.ratelimit.store", "memory"); store {
+	case "memory":
+		limiter.store = newMemoryRateLimitStore()
+	case "postgres":
+		limiter.store = &postgresRateLimitStore{db: db}
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %s", store)
+	}
+
+	if limiter.enabled {
+		idle := cnf.GetDuration("order.ratelimit.idle", 10*time.Minute)
+
+		if refill := limiter.refillTime(); idle < refill {
+			limiter.WarnF("rate limit idle %s raised to the bucket refill time %s", idle, refill)
+			idle = refill
+		}
+
+		go limiter.runPurge(idle)
+	}
+
+	return limiter, nil
+}
+
+// refillTime the longest time an empty bucket takes to refill, a bucket idle that long is full
+// and can be purged, buckets of limits without refill rate are never full again
+func (limiter *rateLimiter) refillTime() time.Duration {
+	var refill time.Duration
+
+	limits := []*rateLimit{limiter.ipLimit, limiter.defaultLimit}
+
+	for _, limit := range limiter.routeLimits {
+		limits = append(limits, limit)
+	}
+
+	for _, limit := range limits {
+		if limit.Rate <= 0 {
+			continue
+		}
+
+		if duration := time.Duration(limit.Burst / limit.Rate * float64(time.Second)); duration > refill {
+			refill = duration
+		}
+	}
+
+	return refill
+}
+
+// runPurge purge the idle buckets every minute
+func (limiter *rateLimiter) runPurge(idle time.Duration) {
+	for range time.Tick(time.Minute) {
+		if err := limiter.store.purge(idle); err != nil {
+			limiter.ErrorF("purge rate limit buckets error, %s", err)
+		}
+	}
+}
+
+// trusted check if ip is a trusted reverse proxy
+func (limiter *rateLimiter) trusted(ip net.IP) bool {
+	for _, proxy := range limiter.proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP the ip of the peer of request, X-Forwarded-For is honored only when the peer is a
+// trusted proxy, and is walked from the right up to the first address not a trusted proxy
+func (limiter *rateLimiter) clientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+
+	if err != nil {
+		host = request.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip == nil || !limiter.trusted(ip) {
+		return host
+	}
+
+	forwarded := strings.Split(request.Header.Get("X-Forwarded-For"), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+
+		if hop == nil {
+			break
+		}
+
+		if ip = hop; !limiter.trusted(ip) {
+			break
+		}
+	}
+
+	return ip.String()
+}
+
+// take take one token of key, aborts ctx with 429 and returns false when the bucket is empty,
+// when the store fails the request passes if order.ratelimit.failopen, otherwise aborts with 503
+func (service *HTTPServer) take(ctx *gin.Context, key string, limit *rateLimit) bool {
+	limiter := service.limiter
+
+	wait, err := limiter.store.take(key, limit, time.Now())
+
+	if err != nil {
+		if limiter.failOpen {
+			limiter.ErrorF("rate limit %s error, the request passes unlimited, %s", key, err)
+			return true
+		}
+
+		limiter.ErrorF("rate limit %s error, the request is refused, %s", key, err)
+		service.abort(ctx, errUnavailable("rate limit unavailable"))
+		return false
+	}
+
+	if wait > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		service.abort(ctx, errTooManyRequests("rate limit exceeded"))
+		return false
+	}
+
+	return true
+}
+
+// limitIP the middleware limiting all the requests of the client ip, installed before the
+// authentication so that the credentials can not be brute forced
+func (service *HTTPServer) limitIP(ctx *gin.Context) {
+	limiter := service.limiter
+
+	if limiter.enabled && !service.take(ctx, "ip:"+limiter.clientIP(ctx.Request), limiter.ipLimit) {
+		return
+	}
+
+	ctx.Next()
+}
+
+// limit returns the middleware limiting route, route is "METHOD path" as registered
+func (service *HTTPServer) limit(route string) gin.HandlerFunc {
+	limiter := service.limiter
+
+	limit, ok := limiter.routeLimits[route]
+
+	if !ok {
+		limit = limiter.defaultLimit
+	}
+
+	return func(ctx *gin.Context) {
+		if !limiter.enabled {
+			ctx.Next()
+			return
+		}
+
+		key := route + "|ip:" + limiter.clientIP(ctx.Request)
+
+		if caller := service.principal(ctx); caller != nil {
+			key = route + "|user:" + caller.UserID
+
+			if caller.UserID == "" {
+				key = route + "|admin"
+			}
+		}
+
+		if !service.take(ctx, key, limit) {
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// handle register route with its rate limiter
+func (service *HTTPServer) handle(method string, path string, handlers ...gin.HandlerFunc) {
+	handlers = append([]gin.HandlerFunc{service.limit(method + " " + path)}, handlers...)
+
+	service.engine.Handle(method, path, handlers...)
+}
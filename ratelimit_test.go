@@ -0,0 +1,163 @@
+package orderservice
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/slf4go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitExhausted(t *testing.T) {
+	limiter, err := newRateLimiter(newTestConfig(t, `{"order": {"ratelimit": {
+		"ip": {"rate": 100, "burst": 100},
+		"routes": {"GET /ping": {"rate": 0.5, "burst": 2}}
+	}}}`), nil)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	service := &HTTPServer{
+		engine:  gin.New(),
+		Logger:  slf4go.Get("test"),
+		limiter: limiter,
+	}
+
+	service.engine.Use(service.limitIP)
+
+	service.handle(http.MethodGet, "/ping", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "pong")
+	})
+
+	get := func(remote string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		request.RemoteAddr = remote
+		request.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+		response := httptest.NewRecorder()
+		service.engine.ServeHTTP(response, request)
+
+		return response
+	}
+
+	for i := 0; i < 2; i++ {
+		response := get("192.0.2.1:1234")
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, "pong", response.Body.String())
+	}
+
+	response := get("192.0.2.1:1234")
+
+	assert.Equal(t, http.StatusTooManyRequests, response.Code)
+	assert.Equal(t, "2", response.Header().Get("Retry-After"))
+	assert.Contains(t, response.Body.String(), "rate_limited")
+
+	// the untrusted X-Forwarded-For does not give a fresh bucket
+	response = get("192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, response.Code)
+
+	assert.Equal(t, http.StatusOK, get("192.0.2.2:1234").Code)
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	limiter, err := newRateLimiter(newTestConfig(t, `{"order": {"ratelimit": {
+		"enabled": false,
+		"proxies": ["10.0.0.0/8", "192.0.2.1"]
+	}}}`), nil)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		remote    string
+		forwarded string
+		ip        string
+	}{
+		{"203.0.113.5:1234", "", "203.0.113.5"},
+		{"203.0.113.5:1234", "198.51.100.1", "203.0.113.5"},
+		{"10.1.2.3:1234", "", "10.1.2.3"},
+		{"10.1.2.3:1234", "198.51.100.1", "198.51.100.1"},
+		{"10.1.2.3:1234", "198.51.100.9, 198.51.100.1, 192.0.2.1", "198.51.100.1"},
+		{"192.0.2.1:1234", "10.0.0.1, 10.0.0.2", "10.0.0.1"},
+		{"10.1.2.3:1234", "garbage", "10.1.2.3"},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = test.remote
+
+		if test.forwarded != "" {
+			request.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+
+		assert.Equal(t, test.ip, limiter.clientIP(request), "%s %s", test.remote, test.forwarded)
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (store failingRateLimitStore) take(key string, limit *rateLimit, now time.Time) (time.Duration, error) {
+	return 0, fmt.Errorf("store down")
+}
+
+func (store failingRateLimitStore) purge(idle time.Duration) error {
+	return fmt.Errorf("store down")
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	for _, failOpen := range []bool{true, false} {
+		limiter, err := newRateLimiter(newTestConfig(t, fmt.Sprintf(`{"order": {"ratelimit": {
+			"enabled": false,
+			"failopen": %t
+		}}}`, failOpen)), nil)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		limiter.enabled = true
+		limiter.store = failingRateLimitStore{}
+
+		service := &HTTPServer{
+			engine:  gin.New(),
+			Logger:  slf4go.Get("test"),
+			limiter: limiter,
+		}
+
+		service.engine.Use(service.limitIP)
+
+		service.handle(http.MethodGet, "/ping", func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, "pong")
+		})
+
+		response := httptest.NewRecorder()
+		service.engine.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+		if failOpen {
+			assert.Equal(t, http.StatusOK, response.Code)
+		} else {
+			assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+			assert.Contains(t, response.Body.String(), "unavailable")
+		}
+	}
+}
+
+func TestRateLimitRefillTime(t *testing.T) {
+	limiter, err := newRateLimiter(newTestConfig(t, `{"order": {"ratelimit": {
+		"enabled": false,
+		"ip": {"rate": 20, "burst": 100},
+		"default": {"rate": 10, "burst": 50},
+		"routes": {"GET /slow": {"rate": 0.01, "burst": 30}, "GET /never": {"rate": 0, "burst": 1}}
+	}}}`), nil)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3000*time.Second, limiter.refillTime())
+	}
+}
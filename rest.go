@@ -1,6 +1,7 @@
 package orderservice
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -18,8 +19,30 @@ import (
 type HTTPServer struct {
 	engine *gin.Engine
 	slf4go.Logger
-	laddr string
-	db    *xorm.Engine
+	laddr              string
+	db                 *xorm.Engine
+	assets             *assetRegistry
+	auth               *authenticator
+	limiter            *rateLimiter
+	verifyWallet       bool
+	challengeDuration  time.Duration
+	channelTokenExpire time.Duration
+	defaultPageLimit   int
+	maxPageLimit       int
+	contextSchema      *jsonSchema
+	maxContextSize     int
+	invoiceExpire      time.Duration
+	backfill           bool
+	backfillBatch      int
+	backfillStale      time.Duration
+	statsDays          int
+	prices             *priceRecorder
+	labels             *labelBook
+	templates          *pushTemplates
+	notifiers          map[string]notifier
+	maxAlerts          int
+	contractAdmin      bool
+	maxSubscriptions   int
 }
 
 // NewHTTPServer .
@@ -30,7 +53,13 @@ func NewHTTPServer(cnf *config.Config) (*HTTPServer, error) {
 	}
 
 	engine := gin.New()
-	engine.Use(gin.Recovery())
+	auth, err := newAuthenticator(cnf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	engine.Use(gin.Recovery(), requestID)
 
 	username := cnf.GetString("order.neodb.username", "xxx")
 	password := cnf.GetString("order.neodb.password", "xxx")
@@ -38,110 +67,1011 @@ func NewHTTPServer(cnf *config.Config) (*HTTPServer, error) {
 	host := cnf.GetString("order.neodb.host", "localhost")
 	scheme := cnf.GetString("order.neodb.schema", "postgres")
 
-	db, err := xorm.NewEngine(
-		"postgres",
-		fmt.Sprintf(
-			"user=%v password=%v host=%v dbname=%v port=%v sslmode=disable",
-			username, password, host, scheme, port,
-		),
-	)
+	db, err := xorm.NewEngine(
+		"postgres",
+		fmt.Sprintf(
+			"user=%v password=%v host=%v dbname=%v port=%v sslmode=disable",
+			username, password, host, scheme, port,
+		),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	service := &HTTPServer{
+		engine:             engine,
+		Logger:             slf4go.Get("neo-order-service"),
+		laddr:              cnf.GetString("order.laddr", ":8000"),
+		db:                 db,
+		assets:             newAssetRegistry(cnf, db),
+		auth:               auth,
+		verifyWallet:       cnf.GetBool("order.wallet.verify", true),
+		challengeDuration:  cnf.GetDuration("order.wallet.challenge", 5*time.Minute),
+		channelTokenExpire: cnf.GetDuration("order.notify.tokenexpire", 30*time.Minute),
+		defaultPageLimit:   int(cnf.GetInt64("order.page.default", 20)),
+		maxPageLimit:       int(cnf.GetInt64("order.page.max", 100)),
+		maxContextSize:     int(cnf.GetInt64("order.context.maxsize", 4096)),
+		invoiceExpire:      cnf.GetDuration("order.invoice.expire", 24*time.Hour),
+		backfill:           cnf.GetBool("order.backfill.enabled", true),
+		backfillBatch:      int(cnf.GetInt64("order.backfill.batch", 500)),
+		backfillStale:      cnf.GetDuration("order.backfill.stale", 10*time.Minute),
+		statsDays:          int(cnf.GetInt64("order.stats.days", 30)),
+		maxAlerts:          int(cnf.GetInt64("order.alerts.max", 20)),
+		maxSubscriptions:   int(cnf.GetInt64("order.subscriptions.max", 20)),
+		contractAdmin:      cnf.GetBool("order.subscriptions.contractadmin", true),
+	}
+
+	if service.notifiers, err = newNotifiers(cnf); err != nil {
+		return nil, err
+	}
+
+	if service.limiter, err = newRateLimiter(cnf, db); err != nil {
+		return nil, err
+	}
+
+	if service.templates, err = newPushTemplates(cnf); err != nil {
+		return nil, err
+	}
+
+	if service.labels, err = newLabelBook(cnf, db); err != nil {
+		return nil, err
+	}
+
+	if service.prices, err = newPriceRecorder(cnf, db, service.assets); err != nil {
+		return nil, err
+	}
+
+	if path := cnf.GetString("order.context.schema", ""); path != "" {
+		if service.contextSchema, err = loadJSONSchema(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := service.assets.seed(cnf); err != nil {
+		return nil, err
+	}
+
+	if err := service.assets.refresh(); err != nil {
+		return nil, err
+	}
+
+	if !auth.enabled {
+		service.Warn("AUTHENTICATION DISABLED: any caller can act on behalf of any userid, set order.auth.enabled to true in production")
+	}
+
+	engine.Use(service.limitIP, service.authenticate)
+
+	service.makeRouters()
+
+	return service, nil
+}
+
+// Run run http service
+func (service *HTTPServer) Run() error {
+	return service.engine.Run(service.laddr)
+}
+
+func (service *HTTPServer) makeRouters() {
+	// not /wallet/challenge, httprouter rejects a static segment beside the :userid wildcard
+	service.handle(http.MethodGet, "/challenge", service.getChallenge)
+
+	service.handle(http.MethodPost, "/channel/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		var request *ChannelToken
+
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		channel, err := validateChannel(service.notifiers, request.Channel, request.Target)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		token, err := service.sendChannelToken(ctx.Param("userid"), channel, request.Target)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, token)
+	})
+
+	service.handle(http.MethodPost, "/wallet/:userid/:address", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		address, err := validateAddress("address", ctx.Param("address"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		locale, err := service.templates.validateLocale("locale", ctx.Query("locale"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		target := ctx.Query("target")
+
+		channel, err := validateChannel(service.notifiers, ctx.Query("channel"), target)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if service.verifyWallet {
+			var proof *WalletProof
+
+			if err := ctx.ShouldBindJSON(&proof); err != nil {
+				service.abort(ctx, newValidationError("body", "%s", err))
+				return
+			}
+
+			if err := service.verifyProof(ctx.Param("userid"), address, proof); err != nil {
+				service.abort(ctx, err)
+				return
+			}
+		}
+
+		if err := service.confirmTarget(ctx.Param("userid"), channel, target, ctx.Query("token")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		wallet, err := service.createWallet(ctx.Param("userid"), address, &WalletSettings{
+			Locale:  locale,
+			Channel: channel,
+			Target:  target,
+		})
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if service.backfill {
+			if _, err := service.startBackfill(address); err != nil {
+				service.WarnF("start backfill %s error, %s", address, err)
+			}
+		}
+
+		ctx.JSON(http.StatusCreated, wallet)
+	})
+
+	service.handle(http.MethodGet, "/wallet/:userid/:address/backfill", func(ctx *gin.Context) {
+		address, err := service.registeredWallet(ctx)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		backfill, err := service.getBackfill(address)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, backfill)
+	})
+
+	service.handle(http.MethodPost, "/wallet/:userid/:address/backfill", func(ctx *gin.Context) {
+		address, err := service.registeredWallet(ctx)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		backfill, err := service.startBackfill(address)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, backfill)
+	})
+
+	service.handle(http.MethodDelete, "/wallet/:userid/:address", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		address, err := validateAddress("address", ctx.Param("address"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.deleteWallet(ctx.Param("userid"), address); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+	})
+
+	service.handle(http.MethodPut, "/wallet/:userid/:address", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		address, err := validateAddress("address", ctx.Param("address"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		var update *WalletUpdate
+
+		if err := ctx.ShouldBindJSON(&update); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		if err := service.validateWalletUpdate(update); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.confirmTarget(ctx.Param("userid"), update.Channel, update.Target, update.Token); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		wallet, err := service.updateWallet(ctx.Param("userid"), address, update)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, wallet)
+	})
+
+	service.handle(http.MethodGet, "/wallets/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		wallets, err := service.listWallets(ctx.Param("userid"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, wallets)
+	})
+
+	service.handle(http.MethodDelete, "/wallets/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		deleted, err := service.deleteWallets(ctx.Param("userid"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"deleted": deleted})
+	})
+
+	service.handle(http.MethodPost, "/contacts/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		var contact *Contact
+
+		if err := ctx.ShouldBindJSON(&contact); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		var err error
+
+		if contact.Address, err = validateAddress("address", contact.Address); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := validateLabel(contact.Label); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		contact.UserID = ctx.Param("userid")
+
+		created, err := service.saveContact(contact)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if created {
+			ctx.JSON(http.StatusCreated, contact)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, contact)
+	})
+
+	service.handle(http.MethodGet, "/contacts/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		contacts, err := service.listContacts(ctx.Param("userid"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, contacts)
+	})
+
+	service.handle(http.MethodDelete, "/contacts/:userid/:address", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		address, err := validateAddress("address", ctx.Param("address"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.deleteContact(ctx.Param("userid"), address); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+	})
+
+	service.handle(http.MethodPost, "/subscriptions/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		var subscription *Subscription
+
+		if err := ctx.ShouldBindJSON(&subscription); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		if err := validateSubscription(subscription); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if subscription.Kind == subscribeContract {
+			if err := service.authorizeContractSubscription(ctx); err != nil {
+				service.abort(ctx, err)
+				return
+			}
+		}
+
+		subscription.UserID = ctx.Param("userid")
+
+		created, err := service.saveSubscription(subscription)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if created {
+			ctx.JSON(http.StatusCreated, subscription)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, subscription)
+	})
+
+	service.handle(http.MethodGet, "/subscriptions/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		subscriptions, err := service.listSubscriptions(ctx.Param("userid"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, subscriptions)
+	})
+
+	service.handle(http.MethodDelete, "/subscriptions/:userid/:target", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		subscription := &Subscription{
+			Kind:   subscriptionKind(ctx.Param("target")),
+			Target: ctx.Param("target"),
+		}
+
+		if err := validateSubscription(subscription); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if subscription.Kind == subscribeContract {
+			if err := service.authorizeContractSubscription(ctx); err != nil {
+				service.abort(ctx, err)
+				return
+			}
+		}
+
+		if err := service.deleteSubscription(ctx.Param("userid"), subscription.Kind, subscription.Target); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+	})
+
+	service.handle(http.MethodPost, "/alerts/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		var rule *AlertRule
+
+		if err := ctx.ShouldBindJSON(&rule); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		rule.ID = 0
+		rule.UserID = ctx.Param("userid")
+
+		if err := service.validateAlertRule(rule); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.createAlertRule(rule); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, rule)
+	})
+
+	service.handle(http.MethodGet, "/alerts/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		rules, err := service.listAlertRules(ctx.Param("userid"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, rules)
+	})
+
+	service.handle(http.MethodDelete, "/alerts/:userid/:id", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+
+		if err != nil || id <= 0 {
+			service.abort(ctx, newValidationError("id", "invalid alert rule id"))
+			return
+		}
+
+		if err := service.deleteAlertRule(ctx.Param("userid"), id); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+	})
+
+	service.handle(http.MethodPost, "/order", func(ctx *gin.Context) {
+		var order *Order
+
+		if err := ctx.ShouldBindJSON(&order); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		if err := service.validateOrder(order); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.authorizeOrder(ctx, order); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		created, err := service.createOrder(order)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, created)
+	})
+
+	// service.engine.POST("/order/:tx", func(ctx *gin.Context) {
+	// 	orderModel := model.OrderModel{DBModel: service.dbmodel}
+
+	// 	if err := orderModel.Confirm(ctx.Param("tx")); err != nil {
+	// 		service.ErrorF("confirm order error :%s", err)
+	// 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// 		return
+	// 	}
+	// })
+
+	service.handle(http.MethodGet, "/order/:tx", func(ctx *gin.Context) {
+		tx, err := normalizeTxID("tx", ctx.Param("tx"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		switch view := ctx.DefaultQuery("view", orderViewOrder); view {
+		case orderViewOrder:
+		case orderViewTx:
+			var address string
+
+			if ctx.Query("address") != "" {
+				if address, err = validateAddress("address", ctx.Query("address")); err != nil {
+					service.abort(ctx, err)
+					return
+				}
+			}
+
+			views, err := service.getTxViews(tx, address)
+
+			if err != nil {
+				service.abort(ctx, err)
+				return
+			}
+
+			ctx.JSON(http.StatusOK, views)
+			return
+		default:
+			service.abort(ctx, newValidationError("view", "view must be %s or %s", orderViewOrder, orderViewTx))
+			return
+		}
+
+		orders, err := service.getOrder(tx)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.labelOrders(ctx, orders); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, orders)
+	})
+
+	service.handle(http.MethodGet, "/fees/:address", func(ctx *gin.Context) {
+		address, err := validateAddress("address", ctx.Param("address"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		since, err := parseTimeQuery(ctx, "since")
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		until, err := parseTimeQuery(ctx, "until")
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		summary, err := service.getFeeSummary(address, since, until)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, summary)
+	})
+
+	service.handle(http.MethodGet, "/stats/:address", func(ctx *gin.Context) {
+		address, err := validateAddress("address", ctx.Param("address"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		until, err := parseTimeQuery(ctx, "until")
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if until == nil {
+			now := time.Now()
+			until = &now
+		}
+
+		since, err := parseTimeQuery(ctx, "since")
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
 
-	service := &HTTPServer{
-		engine: engine,
-		Logger: slf4go.Get("neo-order-service"),
-		laddr:  cnf.GetString("order.laddr", ":8000"),
-		db:     db,
-	}
+		if since == nil {
+			start := until.AddDate(0, 0, 1-service.statsDays)
+			since = &start
+		}
 
-	service.makeRouters()
+		if err := service.authorizeBalance(ctx, address); err != nil {
+			service.abort(ctx, err)
+			return
+		}
 
-	return service, nil
-}
+		stats, err := service.getAddressStats(address, *since, *until)
 
-// Run run http service
-func (service *HTTPServer) Run() error {
-	return service.engine.Run(service.laddr)
-}
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
 
-func (service *HTTPServer) makeRouters() {
-	service.engine.POST("/wallet/:userid/:address", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, stats)
+	})
+
+	service.handle(http.MethodPost, "/invoice", func(ctx *gin.Context) {
+		var invoice *Invoice
+
+		if err := ctx.ShouldBindJSON(&invoice); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		if caller := service.principal(ctx); caller != nil && invoice.UserID == "" {
+			invoice.UserID = caller.UserID
+		}
+
+		if invoice.UserID != "" {
+			if err := service.authorizeUser(ctx, invoice.UserID); err != nil {
+				service.abort(ctx, err)
+				return
+			}
+		}
+
+		if err := service.validateInvoice(invoice); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.authorizeInvoice(ctx, invoice); err != nil {
+			service.abort(ctx, err)
+			return
+		}
 
-		if err := service.createWallet(ctx.Param("userid"), ctx.Param("address")); err != nil {
-			service.ErrorF("create wallet error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		invoice, err := service.createInvoice(invoice)
+
+		if err != nil {
+			service.abort(ctx, err)
 			return
 		}
+
+		ctx.JSON(http.StatusCreated, invoice)
 	})
 
-	service.engine.DELETE("/wallet/:userid/:address", func(ctx *gin.Context) {
-		if err := service.deleteWallet(ctx.Param("address"), ctx.Param("userid")); err != nil {
-			service.ErrorF("create wallet error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	service.handle(http.MethodGet, "/invoice/:id", func(ctx *gin.Context) {
+		invoice, err := service.getInvoice(ctx.Param("id"))
+
+		if err != nil {
+			service.abort(ctx, err)
 			return
 		}
+
+		ctx.JSON(http.StatusOK, invoice)
 	})
 
-	service.engine.POST("/order", func(ctx *gin.Context) {
-		var order *Order
+	service.handle(http.MethodGet, "/notifications/:userid", func(ctx *gin.Context) {
+		if err := service.authorizeUser(ctx, ctx.Param("userid")); err != nil {
+			service.abort(ctx, err)
+			return
+		}
 
-		if err := ctx.ShouldBindJSON(&order); err != nil {
-			service.ErrorF("parse order error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := ctx.Query("status")
+
+		switch status {
+		case "", notificationPending, notificationDelivered, notificationFailed:
+		default:
+			service.abort(ctx, newValidationError("status", "status must be %s, %s or %s",
+				notificationPending, notificationDelivered, notificationFailed))
+			return
+		}
+
+		category := ctx.Query("category")
+
+		switch category {
+		case "", categoryOrder, categoryInvoice, categoryAlert:
+		default:
+			service.abort(ctx, newValidationError("category", "category must be %s, %s or %s",
+				categoryOrder, categoryInvoice, categoryAlert))
+			return
+		}
+
+		var cursor int64
+
+		if value := ctx.Query("cursor"); value != "" {
+			var err error
+
+			if cursor, err = strconv.ParseInt(value, 10, 64); err != nil || cursor <= 0 {
+				service.abort(ctx, newValidationError("cursor", "invalid cursor"))
+				return
+			}
+		}
+
+		limit := service.defaultPageLimit
+
+		if value := ctx.Query("limit"); value != "" {
+			var err error
+
+			if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+				service.abort(ctx, newValidationError("limit", "limit must be a positive integer"))
+				return
+			}
+		}
+
+		if limit > service.maxPageLimit {
+			limit = service.maxPageLimit
+		}
+
+		page, err := service.listNotifications(ctx.Param("userid"), status, category, cursor, limit)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, page)
+	})
+
+	service.handle(http.MethodGet, "/push/preview", func(ctx *gin.Context) {
+		locale, err := service.templates.validateLocale("locale", ctx.Query("locale"))
+
+		if err != nil {
+			service.abort(ctx, err)
 			return
 		}
 
-		if err := service.createOrder(order); err != nil {
-			service.ErrorF("create order error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if locale == "" {
+			locale = service.templates.fallback
+		}
+
+		previews, err := service.templates.preview(locale, ctx.Query("event"))
+
+		if err != nil {
+			service.abort(ctx, err)
 			return
 		}
+
+		ctx.JSON(http.StatusOK, previews)
 	})
 
-	// service.engine.POST("/order/:tx", func(ctx *gin.Context) {
-	// 	orderModel := model.OrderModel{DBModel: service.dbmodel}
+	service.handle(http.MethodGet, "/assets", func(ctx *gin.Context) {
+		assets, err := service.assets.list()
 
-	// 	if err := orderModel.Confirm(ctx.Param("tx")); err != nil {
-	// 		service.ErrorF("confirm order error :%s", err)
-	// 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	// 		return
-	// 	}
-	// })
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, assets)
+	})
+
+	service.handle(http.MethodPost, "/asset", service.adminOnly, func(ctx *gin.Context) {
+		var asset *Asset
+
+		if err := ctx.ShouldBindJSON(&asset); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		if err := asset.validate(); err != nil {
+			service.abort(ctx, newValidationError("asset", "%s", err))
+			return
+		}
+
+		if _, ok := service.assets.get(asset.Asset); ok {
+			service.abort(ctx, errConflict("asset %s already registered", asset.Asset))
+			return
+		}
 
-	service.engine.GET("/order/:tx", func(ctx *gin.Context) {
-		if orders, err := service.getOrder(ctx.Param("tx")); err != nil {
-			service.ErrorF("get orders error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		} else {
-			ctx.JSON(http.StatusOK, orders)
+		if err := service.assets.save(asset); err != nil {
+			service.abort(ctx, err)
+			return
 		}
+
+		ctx.JSON(http.StatusCreated, asset)
 	})
 
-	service.engine.GET("/orders/:address/:asset/:offset/:size", func(ctx *gin.Context) {
-		offset, err := parseInt(ctx, "offset")
+	service.handle(http.MethodPut, "/asset/:asset", service.adminOnly, func(ctx *gin.Context) {
+		var asset *Asset
+
+		if err := ctx.ShouldBindJSON(&asset); err != nil {
+			service.abort(ctx, newValidationError("body", "%s", err))
+			return
+		}
+
+		asset.Asset = ctx.Param("asset")
+
+		if err := asset.validate(); err != nil {
+			service.abort(ctx, newValidationError("asset", "%s", err))
+			return
+		}
+
+		if err := service.assets.save(asset); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, asset)
+	})
+
+	service.handle(http.MethodGet, "/balance/:address", func(ctx *gin.Context) {
+		address, err := validateAddress("address", ctx.Param("address"))
 
 		if err != nil {
-			service.ErrorF("parse page parameter error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			service.abort(ctx, err)
 			return
 		}
 
-		size, err := parseInt(ctx, "size")
+		if err := service.authorizeBalance(ctx, address); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		balances, err := service.getBalances(address)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, balances)
+	})
+
+	service.handle(http.MethodGet, "/orders", func(ctx *gin.Context) {
+		query, err := service.parseOrderQuery(ctx)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if query.View == orderViewTx {
+			page, err := service.queryTxs(query)
+
+			if err != nil {
+				service.abort(ctx, err)
+				return
+			}
+
+			ctx.JSON(http.StatusOK, page)
+			return
+		}
+
+		page, err := service.queryOrders(query)
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.labelOrders(ctx, page.Orders); err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, page)
+	})
+
+	// not /orders/:address/export, httprouter rejects a static segment beside the :asset wildcard
+	service.handle(http.MethodGet, "/export/:address", service.export)
+
+	service.handle(http.MethodGet, "/orders/:address/:asset/:offset/:size", func(ctx *gin.Context) {
+		address, err := validateAddress("address", ctx.Param("address"))
+
+		if err != nil {
+			service.abort(ctx, err)
+			return
+		}
+
+		assets, err := service.validateAssets("asset", parseAssets(ctx.Param("asset")))
 
 		if err != nil {
-			service.ErrorF("parse page parameter error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			service.abort(ctx, err)
+			return
+		}
+
+		offset, err := parseInt(ctx, "offset")
+
+		if err != nil || offset < 0 {
+			service.abort(ctx, newValidationError("offset", "offset must be a non-negative integer"))
+			return
+		}
+
+		size, err := parseInt(ctx, "size")
+
+		if err != nil || size <= 0 {
+			service.abort(ctx, newValidationError("size", "size must be a positive integer"))
 			return
 		}
 
-		orders, err := service.getPagedOrders(ctx.Param("address"), ctx.Param("asset"), offset, size)
+		if size > service.maxPageLimit {
+			size = service.maxPageLimit
+		}
+
+		orders, err := service.getPagedOrders(address, assets, offset, size)
 
 		if err != nil {
-			service.ErrorF("get paged orders error :%s", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			service.abort(ctx, err)
+			return
+		}
+
+		if err := service.labelOrders(ctx, orders); err != nil {
+			service.abort(ctx, err)
 			return
 		}
 
@@ -155,15 +1085,57 @@ func parseInt(ctx *gin.Context, name string) (int, error) {
 	return int(result), err
 }
 
-func (service *HTTPServer) createWallet(userid string, address string) error {
+// Wallet registered user wallet
+type Wallet struct {
+	Address      string             `json:"address"`
+	UserID       string             `json:"userid"`
+	Label        string             `json:"label,omitempty"`
+	Locale       string             `json:"locale,omitempty"`
+	Channel      string             `json:"channel,omitempty"`
+	Target       string             `json:"target,omitempty"`
+	Notification *WalletPreferences `json:"notification"`
+	CreateTime   string             `json:"createTime"`
+}
+
+// createWallet register the wallet of userid with the locale and channel of settings, if any
+// createWallet insert the wallet and its settings in one transaction, so a failed settings
+// insert does not leave a wallet without them
+func (service *HTTPServer) createWallet(userid string, address string, settings *WalletSettings) (_ *Wallet, err error) {
+	session := service.db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
 
 	wallet := &neodb.Wallet{
 		Address: address,
 		UserID:  userid,
 	}
 
-	_, err := service.db.Insert(wallet)
-	return err
+	if _, err = session.Insert(wallet); err != nil {
+		return nil, err
+	}
+
+	if settings.Locale == "" && settings.Channel == "" {
+		return newWallet(wallet, nil), session.Commit()
+	}
+
+	settings.UserID = userid
+	settings.Address = address
+
+	if _, err = session.Insert(settings); err != nil {
+		return nil, err
+	}
+
+	return newWallet(wallet, settings), session.Commit()
 }
 
 func (service *HTTPServer) deleteWallet(userid string, address string) error {
@@ -173,78 +1145,165 @@ func (service *HTTPServer) deleteWallet(userid string, address string) error {
 		UserID:  userid,
 	}
 
-	_, err := service.db.Delete(wallet)
+	deleted, err := service.db.Delete(wallet)
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errNotFound("wallet %s of user %s not found", address, userid)
+	}
+
+	_, err = service.db.Where(`user_i_d = ? and "address" = ?`, userid, address).Delete(new(WalletSettings))
+
 	return err
 }
 
+// export stream the order history of an address
+func (service *HTTPServer) export(ctx *gin.Context) {
+	query, err := service.parseExportQuery(ctx)
+
+	if err != nil {
+		service.abort(ctx, err)
+		return
+	}
+
+	service.exportOrders(ctx, query)
+}
+
+// getChallenge issue a wallet registration challenge
+func (service *HTTPServer) getChallenge(ctx *gin.Context) {
+	userid := ctx.Query("userid")
+
+	if userid == "" {
+		service.abort(ctx, newValidationError("userid", "userid required"))
+		return
+	}
+
+	if err := service.authorizeUser(ctx, userid); err != nil {
+		service.abort(ctx, err)
+		return
+	}
+
+	address, err := validateAddress("address", ctx.Query("address"))
+
+	if err != nil {
+		service.abort(ctx, err)
+		return
+	}
+
+	challenge, err := service.createChallenge(userid, address)
+
+	if err != nil {
+		service.abort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, challenge)
+}
+
 // Order neo order object
 type Order struct {
-	Tx          string  `json:"tx" form:"tx" binding:"required"`
-	From        string  `json:"from" form:"from" binding:"required"`
-	To          string  `json:"to" form:"to" binding:"required"`
-	Asset       string  `json:"asset" form:"asset" binding:"required"`
-	Value       string  `json:"value" form:"value" binding:"required"`
-	CreateTime  string  `json:"createTime" form:"createTime"`
-	ConfirmTime string  `json:"confirmTime" form:"confirmTime"`
-	Context     *string `json:"context,omitempty" xorm:"json"`
+	Tx          string          `json:"tx" form:"tx" binding:"required"`
+	From        string          `json:"from" form:"from" binding:"required"`
+	FromLabel   string          `json:"fromLabel,omitempty" form:"-"`
+	To          string          `json:"to" form:"to" binding:"required"`
+	ToLabel     string          `json:"toLabel,omitempty" form:"-"`
+	Asset       string          `json:"asset" form:"asset" binding:"required"`
+	AssetName   string          `json:"assetName,omitempty" form:"-"`
+	Value       string          `json:"value" form:"value" binding:"required"`
+	CreateTime  string          `json:"createTime" form:"createTime"`
+	ConfirmTime string          `json:"confirmTime" form:"confirmTime"`
+	Fee         string          `json:"fee,omitempty" form:"-"` // GAS fee paid by the tx, confirmed orders only
+	Fiat        *OrderFiat      `json:"fiat,omitempty" form:"-"`
+	Context     json.RawMessage `json:"context,omitempty"`
 }
 
-func (service *HTTPServer) getPagedOrders(address, asset string, offset, size int) ([]*Order, error) {
+func (service *HTTPServer) newOrder(torder *neodb.Order) *Order {
+	createTime := torder.CreateTime.Format(time.RFC3339Nano)
 
-	service.DebugF("get address(%s) orders(%s) (%d,%d)", address, asset, offset, size)
+	var confirmTime string
+
+	if torder.ConfirmTime != nil {
+		confirmTime = torder.ConfirmTime.Format(time.RFC3339Nano)
+	}
+
+	order := &Order{
+		Tx:          torder.TX,
+		From:        torder.From,
+		To:          torder.To,
+		Asset:       torder.Asset,
+		AssetName:   service.assets.name(torder.Asset),
+		Value:       service.assets.formatValue(torder.Asset, torder.Value),
+		CreateTime:  createTime,
+		ConfirmTime: confirmTime,
+	}
+
+	if torder.Context != nil {
+		order.Context = json.RawMessage(*torder.Context)
+	}
+
+	return order
+}
+
+func (service *HTTPServer) getPagedOrders(address string, assets []string, offset, size int) ([]*Order, error) {
+
+	service.DebugF("get address(%s) orders(%v) (%d,%d)", address, assets, offset, size)
 
 	torders := make([]*neodb.Order, 0)
 
-	err := service.db.
-		Where(`("from" = ? or "to" = ?) and asset = ?`, address, address, asset).
-		Desc("create_time").
-		Limit(size, offset).
+	session := service.db.Where(`("from" = ? or "to" = ?)`, address, address)
+
+	if len(assets) > 0 {
+		session = session.In("asset", assets)
+	}
+
+	err := session.
+		Desc("create_time", "id").
+		Limit(size, offset*size).
 		Find(&torders)
 
 	if err != nil {
 		return make([]*Order, 0), err
 	}
 
-	orders := make([]*Order, 0)
+	return service.newOrders(torders)
+}
 
-	for _, torder := range torders {
-		createTime := torder.CreateTime.Format(time.RFC3339Nano)
+func (service *HTTPServer) createOrder(order *Order) (*Order, error) {
 
-		var confirmTime string
+	exists, err := service.db.
+		Where(`t_x = ? and "from" = ? and "to" = ? and asset = ?`, order.Tx, order.From, order.To, order.Asset).
+		Exist(new(neodb.Order))
 
-		if torder.ConfirmTime != nil {
-			confirmTime = torder.ConfirmTime.Format(time.RFC3339Nano)
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		orders = append(orders, &Order{
-			Tx:          torder.TX,
-			From:        torder.From,
-			To:          torder.To,
-			Asset:       torder.Asset,
-			Value:       torder.Value,
-			Context:     torder.Context,
-			CreateTime:  createTime,
-			ConfirmTime: confirmTime,
-		})
+	if exists {
+		return nil, errConflict("order %s already exists", order.Tx)
 	}
 
-	return orders, nil
-}
+	tOrder := &neodb.Order{
+		TX:    order.Tx,
+		From:  order.From,
+		To:    order.To,
+		Asset: order.Asset,
+		Value: order.Value,
+		Block: -1,
+	}
 
-func (service *HTTPServer) createOrder(order *Order) error {
+	if len(order.Context) > 0 {
+		context := string(order.Context)
+		tOrder.Context = &context
+	}
 
-	tOrder := &neodb.Order{
-		TX:      order.Tx,
-		From:    order.From,
-		To:      order.To,
-		Asset:   order.Asset,
-		Value:   order.Value,
-		Context: order.Context,
-		Block:   -1,
+	if _, err := service.db.Insert(tOrder); err != nil {
+		return nil, err
 	}
 
-	_, err := service.db.Insert(tOrder)
-	return err
+	return service.newOrder(tOrder), nil
 }
 
 func (service *HTTPServer) getOrder(tx string) ([]*Order, error) {
@@ -259,30 +1318,11 @@ func (service *HTTPServer) getOrder(tx string) ([]*Order, error) {
 		return make([]*Order, 0), err
 	}
 
-	orders := make([]*Order, 0)
-
-	for _, torder := range torders {
-		createTime := torder.CreateTime.Format(time.RFC3339Nano)
-
-		var confirmTime string
-
-		if torder.ConfirmTime != nil {
-			confirmTime = torder.ConfirmTime.Format(time.RFC3339Nano)
-		}
-
-		orders = append(orders, &Order{
-			Tx:          torder.TX,
-			From:        torder.From,
-			To:          torder.To,
-			Asset:       torder.Asset,
-			Value:       torder.Value,
-			Context:     torder.Context,
-			CreateTime:  createTime,
-			ConfirmTime: confirmTime,
-		})
+	if len(torders) == 0 {
+		return nil, errNotFound("order %s not found", tx)
 	}
 
-	return orders, nil
+	return service.newOrders(torders)
 }
 
 func parsePage(offset string, size string) (*model.Page, error) {
@@ -0,0 +1,262 @@
+package orderservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// jsonSchema the subset of JSON Schema used to validate order context: type, enum,
+// properties, required, additionalProperties, maxProperties, items, minItems, maxItems,
+// minLength, maxLength, pattern, minimum and maximum
+type jsonSchema struct {
+	Type                 interface{}            `json:"type"` // type name or array of type names
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"` // bool or schema
+	MaxProperties        *int                   `json:"maxProperties"`
+	Items                *jsonSchema            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+
+	types        []string
+	pattern      *regexp.Regexp
+	noAdditional bool
+	additional   *jsonSchema
+}
+
+// loadJSONSchema load and compile the schema file
+func loadJSONSchema(path string) (*jsonSchema, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schema := new(jsonSchema)
+
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("parse schema %s error, %s", path, err)
+	}
+
+	if err := schema.compile(); err != nil {
+		return nil, fmt.Errorf("compile schema %s error, %s", path, err)
+	}
+
+	return schema, nil
+}
+
+func (schema *jsonSchema) compile() error {
+	switch t := schema.Type.(type) {
+	case nil:
+	case string:
+		schema.types = []string{t}
+	case []interface{}:
+		for _, name := range t {
+			name, ok := name.(string)
+
+			if !ok {
+				return fmt.Errorf("type must be string or array of strings")
+			}
+
+			schema.types = append(schema.types, name)
+		}
+	default:
+		return fmt.Errorf("type must be string or array of strings")
+	}
+
+	if schema.Pattern != "" {
+		pattern, err := regexp.Compile(schema.Pattern)
+
+		if err != nil {
+			return err
+		}
+
+		schema.pattern = pattern
+	}
+
+	if len(schema.AdditionalProperties) > 0 {
+		var allowed bool
+
+		if err := json.Unmarshal(schema.AdditionalProperties, &allowed); err == nil {
+			schema.noAdditional = !allowed
+		} else {
+			schema.additional = new(jsonSchema)
+
+			if err := json.Unmarshal(schema.AdditionalProperties, schema.additional); err != nil {
+				return fmt.Errorf("additionalProperties must be bool or schema")
+			}
+
+			if err := schema.additional.compile(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, property := range schema.Properties {
+		if err := property.compile(); err != nil {
+			return err
+		}
+	}
+
+	if schema.Items != nil {
+		return schema.Items.compile()
+	}
+
+	return nil
+}
+
+// jsonType the JSON Schema type name of decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// validate validate the decoded value, path is the field name reported on error
+func (schema *jsonSchema) validate(path string, value interface{}) error {
+	if len(schema.types) > 0 {
+		actual := jsonType(value)
+
+		var matched bool
+
+		for _, name := range schema.types {
+			if name == actual || (name == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return newValidationError(path, "%s must be %v", path, schema.types)
+		}
+	}
+
+	if len(schema.Enum) > 0 {
+		var matched bool
+
+		for _, option := range schema.Enum {
+			if reflect.DeepEqual(option, value) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return newValidationError(path, "%s must be one of %v", path, schema.Enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return newValidationError(path, "%s must be at least %d chars", path, *schema.MinLength)
+		}
+
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return newValidationError(path, "%s must be at most %d chars", path, *schema.MaxLength)
+		}
+
+		if schema.pattern != nil && !schema.pattern.MatchString(v) {
+			return newValidationError(path, "%s must match %s", path, schema.Pattern)
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			return newValidationError(path, "%s must be >= %v", path, *schema.Minimum)
+		}
+
+		if schema.Maximum != nil && v > *schema.Maximum {
+			return newValidationError(path, "%s must be <= %v", path, *schema.Maximum)
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			return newValidationError(path, "%s must have at least %d items", path, *schema.MinItems)
+		}
+
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			return newValidationError(path, "%s must have at most %d items", path, *schema.MaxItems)
+		}
+
+		if schema.Items != nil {
+			for i, item := range v {
+				if err := schema.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		return schema.validateObject(path, v)
+	}
+
+	return nil
+}
+
+func (schema *jsonSchema) validateObject(path string, object map[string]interface{}) error {
+	if schema.MaxProperties != nil && len(object) > *schema.MaxProperties {
+		return newValidationError(path, "%s must have at most %d properties", path, *schema.MaxProperties)
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			return newValidationError(path+"."+name, "%s.%s required", path, name)
+		}
+	}
+
+	names := make([]string, 0, len(object))
+
+	for name := range object {
+		names = append(names, name)
+	}
+
+	// report the first invalid property in a stable order
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := schema.Properties[name]
+
+		if !ok {
+			if schema.noAdditional {
+				return newValidationError(path+"."+name, "%s.%s not allowed", path, name)
+			}
+
+			property = schema.additional
+		}
+
+		if property == nil {
+			continue
+		}
+
+		if err := property.validate(path+"."+name, object[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
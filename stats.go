@@ -0,0 +1,430 @@
+package orderservice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dynamicgo/config"
+	"github.com/dynamicgo/slf4go"
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neodb"
+)
+
+// AddressDaily confirmed transfers of one address and asset aggregated by day in the database timezone,
+// maintained incrementally by the tx watcher
+type AddressDaily struct {
+	ID          int64     `xorm:"pk autoincr"`
+	Address     string    `xorm:"notnull unique(address_daily)"`
+	Asset       string    `xorm:"notnull unique(address_daily)"`
+	Day         time.Time `xorm:"DATE notnull unique(address_daily)"`
+	Received    string    `xorm:"NUMERIC notnull"` // from other addresses
+	Sent        string    `xorm:"NUMERIC notnull"` // to other addresses, change excluded
+	ReceivedTxs int64     `xorm:"notnull"`
+	SentTxs     int64     `xorm:"notnull"`
+	Txs         int64     `xorm:"notnull"`
+	FirstTime   time.Time `xorm:"TIMESTAMP notnull"`
+	LastTime    time.Time `xorm:"TIMESTAMP notnull"`
+}
+
+// TableName xorm table name
+func (table *AddressDaily) TableName() string {
+	return "neo_address_daily"
+}
+
+// AddressStatsTx the txs already counted in neo_address_daily, one row per tx, address and asset
+type AddressStatsTx struct {
+	ID         int64     `xorm:"pk autoincr"`
+	TX         string    `xorm:"notnull unique(address_stats_tx)"`
+	Address    string    `xorm:"notnull unique(address_stats_tx)"`
+	Asset      string    `xorm:"notnull unique(address_stats_tx)"`
+	CreateTime time.Time `xorm:"TIMESTAMP notnull created"`
+}
+
+// TableName xorm table name
+func (table *AddressStatsTx) TableName() string {
+	return "neo_address_stats_tx"
+}
+
+// AssetStats totals of one asset of the stats api
+type AssetStats struct {
+	Asset         string `json:"asset"`
+	AssetName     string `json:"assetName,omitempty"`
+	Received      string `json:"received"`
+	Sent          string `json:"sent"`
+	ReceivedTxs   int64  `json:"receivedTxs"`
+	SentTxs       int64  `json:"sentTxs"`
+	Txs           int64  `json:"txs"`
+	FirstActivity string `json:"firstActivity"`
+	LastActivity  string `json:"lastActivity"`
+}
+
+// DailyStats one day of one asset of the stats api
+type DailyStats struct {
+	Day      string `json:"day"`
+	Asset    string `json:"asset"`
+	Received string `json:"received"`
+	Sent     string `json:"sent"`
+	Txs      int64  `json:"txs"`
+}
+
+// AddressStats the stats api object
+type AddressStats struct {
+	Address string        `json:"address"`
+	Assets  []*AssetStats `json:"assets"`
+	Daily   []*DailyStats `json:"daily"`
+}
+
+// statsDelta the transfers of one tx and asset relative to one address
+type statsDelta struct {
+	tx       string
+	address  string
+	asset    string
+	received Amount
+	sent     Amount
+	time     time.Time
+}
+
+// newStatsDeltas group the confirmed orders by tx, asset and party, change sent back to
+// the sender is neither received nor sent but still counts the tx
+func newStatsDeltas(orders []*neodb.Order, assets *assetRegistry) ([]*statsDelta, error) {
+	deltas := make([]*statsDelta, 0)
+	index := make(map[string]*statsDelta)
+
+	for _, order := range orders {
+		if order.ConfirmTime == nil {
+			continue
+		}
+
+		value, err := assets.parseValue(order.Asset, order.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, address := range []string{order.From, order.To} {
+			key := order.TX + "|" + address + "|" + order.Asset
+
+			delta, ok := index[key]
+
+			if !ok {
+				zero := Amount{Decimals: value.Decimals}
+
+				delta = &statsDelta{
+					tx:       order.TX,
+					address:  address,
+					asset:    order.Asset,
+					received: zero,
+					sent:     zero,
+					time:     *order.ConfirmTime,
+				}
+
+				index[key] = delta
+				deltas = append(deltas, delta)
+			}
+
+			if order.From == order.To {
+				break
+			}
+
+			if address == order.To {
+				delta.received, err = delta.received.Add(value)
+			} else {
+				delta.sent, err = delta.sent.Add(value)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return deltas, nil
+}
+
+func countTx(amount Amount) int {
+	if amount.IsPositive() {
+		return 1
+	}
+
+	return 0
+}
+
+// lockStats serialize the stats updates of address until the end of the transaction
+func lockStats(session *xorm.Session, address string) error {
+	_, err := session.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", address)
+
+	return err
+}
+
+// applyStats add the confirmed orders to the daily stats of their parties, txs already
+// counted are skipped so orders confirmed twice are only counted once
+func applyStats(db *xorm.Engine, orders []*neodb.Order, assets *assetRegistry) error {
+	deltas, err := newStatsDeltas(orders, assets)
+
+	if err != nil {
+		return err
+	}
+
+	for _, delta := range deltas {
+		if err := applyStatsDelta(db, delta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyStatsDelta(db *xorm.Engine, delta *statsDelta) (err error) {
+	session := db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
+
+	if err = lockStats(session, delta.address); err != nil {
+		return err
+	}
+
+	result, err := session.Exec(
+		`INSERT INTO neo_address_stats_tx (t_x, "address", asset, create_time) VALUES (?, ?, ?, ?) ON CONFLICT (t_x, "address", asset) DO NOTHING`,
+		delta.tx, delta.address, delta.asset, formatDBTime(time.Now(), db.DatabaseTZ),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	counted, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if counted == 0 {
+		return session.Commit()
+	}
+
+	t := formatDBTime(delta.time, db.DatabaseTZ)
+
+	_, err = session.Exec(`INSERT INTO neo_address_daily
+  ("address", asset, "day", received, sent, received_txs, sent_txs, txs, first_time, last_time)
+VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+ON CONFLICT ("address", asset, "day") DO UPDATE SET
+  received = neo_address_daily.received + excluded.received,
+  sent = neo_address_daily.sent + excluded.sent,
+  received_txs = neo_address_daily.received_txs + excluded.received_txs,
+  sent_txs = neo_address_daily.sent_txs + excluded.sent_txs,
+  txs = neo_address_daily.txs + 1,
+  first_time = least(neo_address_daily.first_time, excluded.first_time),
+  last_time = greatest(neo_address_daily.last_time, excluded.last_time)`,
+		delta.address, delta.asset, delta.time.In(db.DatabaseTZ).Format("2006-01-02"),
+		delta.received.String(), delta.sent.String(), countTx(delta.received), countTx(delta.sent), t, t,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return session.Commit()
+}
+
+// rebuildStats recompute the stats of address from its confirmed orders, used when orders
+// are created outside the tx watcher such as by a backfill
+func rebuildStats(db *xorm.Engine, address string) (err error) {
+	session := db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
+
+	if err = lockStats(session, address); err != nil {
+		return err
+	}
+
+	if _, err = session.Exec(`DELETE FROM neo_address_stats_tx WHERE "address" = ?`, address); err != nil {
+		return err
+	}
+
+	if _, err = session.Exec(`DELETE FROM neo_address_daily WHERE "address" = ?`, address); err != nil {
+		return err
+	}
+
+	_, err = session.Exec(`INSERT INTO neo_address_stats_tx (t_x, "address", asset, create_time)
+SELECT DISTINCT t_x, ?::varchar, asset, ?::timestamp FROM neo_order
+WHERE ("from" = ? or "to" = ?) and confirm_time is not null`,
+		address, formatDBTime(time.Now(), db.DatabaseTZ), address, address,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = session.Exec(`INSERT INTO neo_address_daily
+  ("address", asset, "day", received, sent, received_txs, sent_txs, txs, first_time, last_time)
+SELECT ?::varchar, asset, "day", sum(received), sum(sent),
+  count(*) filter (where received > 0), count(*) filter (where sent > 0), count(*), min(t), max(t)
+FROM (
+  SELECT t_x, asset, min(confirm_time)::date as "day", min(confirm_time) as t,
+    coalesce(sum(value::numeric) filter (where "to" = ? and "from" <> ?), 0) as received,
+    coalesce(sum(value::numeric) filter (where "from" = ? and "to" <> ?), 0) as sent
+  FROM neo_order
+  WHERE ("from" = ? or "to" = ?) and confirm_time is not null
+  GROUP BY t_x, asset
+) txs
+GROUP BY asset, "day"`,
+		address, address, address, address, address, address, address,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return session.Commit()
+}
+
+// RebuildAllStats one-off rebuild of the stats of every wallet and subscribed address from their
+// confirmed orders, for the addresses registered before the stats were maintained by the tx watcher
+func RebuildAllStats(conf *config.Config) error {
+	logger := slf4go.Get("stats-rebuild")
+
+	username := conf.GetString("order.neodb.username", "xxx")
+	password := conf.GetString("order.neodb.password", "xxx")
+	port := conf.GetString("order.neodb.port", "6543")
+	host := conf.GetString("order.neodb.host", "localhost")
+	scheme := conf.GetString("order.neodb.schema", "postgres")
+
+	db, err := xorm.NewEngine(
+		"postgres",
+		fmt.Sprintf(
+			"user=%v password=%v host=%v dbname=%v port=%v sslmode=disable",
+			username, password, host, scheme, port,
+		),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	var addresses []string
+
+	err = db.SQL(`SELECT "address" FROM neo_wallet UNION SELECT target FROM neo_subscription WHERE kind = ? ORDER BY 1`,
+		subscribeAddress).Find(&addresses)
+
+	if err != nil {
+		return err
+	}
+
+	for i, address := range addresses {
+		if err := rebuildStats(db, address); err != nil {
+			return fmt.Errorf("rebuild stats of %s error, %s", address, err)
+		}
+
+		logger.DebugF("rebuilt stats of %s (%d/%d)", address, i+1, len(addresses))
+	}
+
+	logger.InfoF("rebuilt stats of %d addresses", len(addresses))
+
+	return nil
+}
+
+// assetTotals row of the per asset sums of neo_address_daily
+type assetTotals struct {
+	Asset       string    `xorm:"asset"`
+	Received    string    `xorm:"received"`
+	Sent        string    `xorm:"sent"`
+	ReceivedTxs int64     `xorm:"received_txs"`
+	SentTxs     int64     `xorm:"sent_txs"`
+	Txs         int64     `xorm:"txs"`
+	FirstTime   time.Time `xorm:"first_time"`
+	LastTime    time.Time `xorm:"last_time"`
+}
+
+// formatStatsValue normalize a NUMERIC sum to the asset decimals
+func (service *HTTPServer) formatStatsValue(asset string, value string) string {
+	amount, err := service.assets.parseValue(asset, value)
+
+	if err != nil {
+		return value
+	}
+
+	return amount.String()
+}
+
+// getAddressStats the all time totals of address and its daily series from the day of since to the day of until
+func (service *HTTPServer) getAddressStats(address string, since time.Time, until time.Time) (*AddressStats, error) {
+	stats := &AddressStats{
+		Address: address,
+		Assets:  make([]*AssetStats, 0),
+		Daily:   make([]*DailyStats, 0),
+	}
+
+	var totals []*assetTotals
+
+	err := service.db.Table(new(AddressDaily)).
+		Select("asset, sum(received) as received, sum(sent) as sent, sum(received_txs) as received_txs, "+
+			"sum(sent_txs) as sent_txs, sum(txs) as txs, min(first_time) as first_time, max(last_time) as last_time").
+		Where(`"address" = ?`, address).
+		GroupBy("asset").
+		Asc("asset").
+		Find(&totals)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, total := range totals {
+		stats.Assets = append(stats.Assets, &AssetStats{
+			Asset:         total.Asset,
+			AssetName:     service.assets.name(total.Asset),
+			Received:      service.formatStatsValue(total.Asset, total.Received),
+			Sent:          service.formatStatsValue(total.Asset, total.Sent),
+			ReceivedTxs:   total.ReceivedTxs,
+			SentTxs:       total.SentTxs,
+			Txs:           total.Txs,
+			FirstActivity: total.FirstTime.Format(time.RFC3339Nano),
+			LastActivity:  total.LastTime.Format(time.RFC3339Nano),
+		})
+	}
+
+	var days []*AddressDaily
+
+	err = service.db.
+		Where(`"address" = ? and "day" >= ? and "day" <= ?`, address,
+			since.In(service.db.DatabaseTZ).Format("2006-01-02"), until.In(service.db.DatabaseTZ).Format("2006-01-02")).
+		Asc("day", "asset").
+		Find(&days)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, day := range days {
+		stats.Daily = append(stats.Daily, &DailyStats{
+			Day:      day.Day.Format("2006-01-02"),
+			Asset:    day.Asset,
+			Received: service.formatStatsValue(day.Asset, day.Received),
+			Sent:     service.formatStatsValue(day.Asset, day.Sent),
+			Txs:      day.Txs,
+		})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,127 @@
+package orderservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/inwecrypto/neodb"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAssets registry of the builtin assets which never reloads from db
+func newTestAssets(t *testing.T) *assetRegistry {
+	assets := newAssetRegistry(newTestConfig(t, `{}`), nil)
+	assets.refreshTime = time.Now()
+	assets.refreshDuration = time.Hour
+
+	return assets
+}
+
+func TestNewStatsDeltas(t *testing.T) {
+	assets := newTestAssets(t)
+	confirmTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	order := func(tx string, from string, to string, asset string, value string) *neodb.Order {
+		return &neodb.Order{TX: tx, From: from, To: to, Asset: asset, Value: value, ConfirmTime: &confirmTime}
+	}
+
+	// delta as tx|address|asset -> received/sent
+	type result map[string][2]string
+
+	tests := []struct {
+		name   string
+		orders []*neodb.Order
+		deltas result
+	}{
+		{
+			name: "transfer with change",
+			orders: []*neodb.Order{
+				order("tx1", "A", "B", neoAsset, "10"),
+				order("tx1", "A", "A", neoAsset, "5"),
+			},
+			deltas: result{
+				"tx1|A|" + neoAsset: {"0", "10"},
+				"tx1|B|" + neoAsset: {"10", "0"},
+			},
+		},
+		{
+			name: "change only",
+			orders: []*neodb.Order{
+				order("tx2", "A", "A", gasAsset, "1.5"),
+			},
+			deltas: result{
+				"tx2|A|" + gasAsset: {"0", "0"},
+			},
+		},
+		{
+			name: "send and receive in the same tx",
+			orders: []*neodb.Order{
+				order("tx3", "A", "B", gasAsset, "2"),
+				order("tx3", "B", "A", gasAsset, "0.5"),
+				order("tx3", "A", "C", gasAsset, "1"),
+			},
+			deltas: result{
+				"tx3|A|" + gasAsset: {"0.5", "3"},
+				"tx3|B|" + gasAsset: {"2", "0.5"},
+				"tx3|C|" + gasAsset: {"1", "0"},
+			},
+		},
+		{
+			name: "assets of one tx are separate",
+			orders: []*neodb.Order{
+				order("tx4", "A", "B", neoAsset, "1"),
+				order("tx4", "A", "B", gasAsset, "0.1"),
+			},
+			deltas: result{
+				"tx4|A|" + neoAsset: {"0", "1"},
+				"tx4|B|" + neoAsset: {"1", "0"},
+				"tx4|A|" + gasAsset: {"0", "0.1"},
+				"tx4|B|" + gasAsset: {"0.1", "0"},
+			},
+		},
+		{
+			name: "pending orders are skipped",
+			orders: []*neodb.Order{
+				{TX: "tx5", From: "A", To: "B", Asset: neoAsset, Value: "1"},
+			},
+			deltas: result{},
+		},
+	}
+
+	for _, test := range tests {
+		deltas, err := newStatsDeltas(test.orders, assets)
+
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+
+		got := make(result)
+
+		for _, delta := range deltas {
+			got[delta.tx+"|"+delta.address+"|"+delta.asset] = [2]string{delta.received.String(), delta.sent.String()}
+			assert.Equal(t, confirmTime, delta.time, test.name)
+		}
+
+		assert.Equal(t, test.deltas, got, test.name)
+	}
+}
+
+// TestNewStatsDeltasDuplicateConfirm a replayed confirm yields the same tx, address and asset
+// keys, which applyStatsDelta records in neo_address_stats_tx to count them once
+func TestNewStatsDeltasDuplicateConfirm(t *testing.T) {
+	assets := newTestAssets(t)
+	confirmTime := time.Now()
+
+	orders := []*neodb.Order{
+		{TX: "tx1", From: "A", To: "B", Asset: neoAsset, Value: "10", ConfirmTime: &confirmTime},
+		{TX: "tx1", From: "A", To: "A", Asset: neoAsset, Value: "5", ConfirmTime: &confirmTime},
+	}
+
+	first, err := newStatsDeltas(orders, assets)
+	assert.NoError(t, err)
+
+	second, err := newStatsDeltas(orders, assets)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+}
@@ -0,0 +1,275 @@
+package orderservice
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+	"github.com/inwecrypto/neodb"
+)
+
+// subscription kinds
+const (
+	subscribeAddress  = "address"  // transfers from or to the address
+	subscribeContract = "contract" // transfers of the nep5 contract
+)
+
+// Subscription watch-only subscription of a user to an address or a contract script hash,
+// the user is notified of the transfers without claiming the ownership of the address
+type Subscription struct {
+	ID          int64     `json:"-" xorm:"pk autoincr"`
+	UserID      string    `json:"userid" xorm:"notnull unique(user_subscription)"`
+	Kind        string    `json:"kind" xorm:"notnull unique(user_subscription)"`
+	Target      string    `json:"target" xorm:"notnull unique(user_subscription) index"` // address or contract script hash
+	Label       string    `json:"label,omitempty" xorm:"notnull default ''"`
+	HideBalance bool      `json:"hideBalance" xorm:"notnull default false"` // the stats and balance refuse the address to non-admins
+	CreateTime  time.Time `json:"-" xorm:"TIMESTAMP notnull created"`
+}
+
+// TableName xorm table name
+func (table *Subscription) TableName() string {
+	return "neo_subscription"
+}
+
+// validateSubscription validate and normalize the subscription of create subscription request
+func validateSubscription(subscription *Subscription) (err error) {
+	if len(subscription.Label) > 64 {
+		return newValidationError("label", "label must be at most 64 chars")
+	}
+
+	switch subscription.Kind {
+	case subscribeAddress:
+		subscription.Target, err = validateAddress("target", subscription.Target)
+
+		return err
+	case subscribeContract:
+		subscription.Target, err = normalizeScriptHash("target", subscription.Target)
+
+		if err != nil {
+			return err
+		}
+
+		if subscription.HideBalance {
+			return newValidationError("hideBalance", "hideBalance is for %s subscriptions only", subscribeAddress)
+		}
+
+		return nil
+	}
+
+	return newValidationError("kind", "kind must be %s or %s", subscribeAddress, subscribeContract)
+}
+
+// normalizeScriptHash check value is a 20 bytes hex script hash, and normalize it to lower case with 0x prefix
+func normalizeScriptHash(field string, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.TrimPrefix(value, "0x")
+
+	if len(value) != 40 || !isHex(value) {
+		return "", newValidationError(field, "script hash must be 20 bytes hex string")
+	}
+
+	return "0x" + value, nil
+}
+
+// subscriptionKind the kind of the subscription target, an address or a contract script hash
+func subscriptionKind(target string) string {
+	if strings.HasPrefix(strings.ToLower(target), "0x") {
+		return subscribeContract
+	}
+
+	return subscribeAddress
+}
+
+// saveSubscription insert or update the subscription of userid, returns true if it was created,
+// the subscriptions of a user are limited to maxSubscriptions apart from the wallets, the count
+// and the insert are serialized per user so concurrent requests can not exceed the limit
+func (service *HTTPServer) saveSubscription(subscription *Subscription) (created bool, err error) {
+	session := service.db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
+
+	if _, err = session.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "subscription:"+subscription.UserID); err != nil {
+		return false, err
+	}
+
+	updated, err := session.
+		Where("user_i_d = ? and kind = ? and target = ?", subscription.UserID, subscription.Kind, subscription.Target).
+		Cols("label", "hide_balance").
+		Update(subscription)
+
+	if err != nil {
+		return false, err
+	}
+
+	if updated != 0 {
+		return false, session.Commit()
+	}
+
+	count, err := session.Where("user_i_d = ?", subscription.UserID).Count(new(Subscription))
+
+	if err != nil {
+		return false, err
+	}
+
+	if count >= int64(service.maxSubscriptions) {
+		err = errConflict("user %s already has %d subscriptions", subscription.UserID, count)
+		return false, err
+	}
+
+	if _, err = session.Insert(subscription); err != nil {
+		return false, err
+	}
+
+	return true, session.Commit()
+}
+
+// listSubscriptions the subscriptions of userid in creation order
+func (service *HTTPServer) listSubscriptions(userid string) ([]*Subscription, error) {
+	subscriptions := make([]*Subscription, 0)
+
+	if err := service.db.Where("user_i_d = ?", userid).Asc("id").Find(&subscriptions); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (service *HTTPServer) deleteSubscription(userid string, kind string, target string) error {
+	deleted, err := service.db.
+		Where("user_i_d = ? and kind = ? and target = ?", userid, kind, target).
+		Delete(new(Subscription))
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errNotFound("subscription %s of user %s not found", target, userid)
+	}
+
+	return nil
+}
+
+// authorizeBalance refuse the balance of address to every caller but the admins if a user subscribed
+// to it with hideBalance, unless the caller registered it as a wallet
+func (service *HTTPServer) authorizeBalance(ctx *gin.Context, address string) error {
+	caller := service.principal(ctx)
+
+	if caller != nil && caller.Admin {
+		return nil
+	}
+
+	hidden, err := service.db.
+		Where("kind = ? and target = ? and hide_balance", subscribeAddress, address).
+		Exist(new(Subscription))
+
+	if err != nil || !hidden {
+		return err
+	}
+
+	if caller != nil && caller.UserID != "" {
+		owned, err := service.db.Where(`user_i_d = ? and "address" = ?`, caller.UserID, address).Exist(new(neodb.Wallet))
+
+		if err != nil || owned {
+			return err
+		}
+	}
+
+	return errForbidden("the balance of address %s is hidden", address)
+}
+
+// authorizeContractSubscription contract subscriptions make the tx watcher track every transfer
+// of the token, they require an admin unless order.subscriptions.contractadmin is false
+func (service *HTTPServer) authorizeContractSubscription(ctx *gin.Context) error {
+	caller := service.principal(ctx)
+
+	if caller != nil && (caller.Admin || !service.contractAdmin) {
+		return nil
+	}
+
+	if service.contractAdmin {
+		return errForbidden("contract subscriptions are reserved to admins")
+	}
+
+	return errForbidden("contract subscriptions require authentication")
+}
+
+// isSubscribed check if a subscription watches the from or to address or the asset contract,
+// so the transfer is tracked as an order even if no address is a registered wallet
+func (watcher *TxWatcher) isSubscribed(tx *neodb.Tx) (bool, error) {
+	return watcher.db.
+		Where("(kind = ? and target in (?, ?)) or (kind = ? and target = ?)",
+			subscribeAddress, tx.From, tx.To, subscribeContract, tx.Asset).
+		Exist(new(Subscription))
+}
+
+// notifySubscribers queue the notifications of order to the users subscribed to its
+// addresses or contract within session, except the users notified already as wallet owners
+func (watcher *TxWatcher) notifySubscribers(session *xorm.Session, order *neodb.Order, notified map[string]bool) error {
+	subscriptions := make([]*Subscription, 0)
+
+	err := watcher.db.
+		Where("(kind = ? and target in (?, ?)) or (kind = ? and target = ?)",
+			subscribeAddress, order.From, order.To, subscribeContract, order.Asset).
+		Asc("id").
+		Find(&subscriptions)
+
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		if notified[subscription.UserID] {
+			continue
+		}
+
+		notified[subscription.UserID] = true
+
+		labels, err := watcher.labels.labels(subscription.UserID, []string{order.From, order.To})
+
+		if err != nil {
+			return err
+		}
+
+		data := &PushData{
+			Address:      order.To,
+			Counterparty: order.From,
+			Asset:        order.Asset,
+			AssetName:    watcher.assets.name(order.Asset),
+			Value:        watcher.assets.formatValue(order.Asset, order.Value),
+			TX:           order.TX,
+		}
+
+		event := pushReceived
+
+		switch {
+		case subscription.Kind == subscribeContract:
+			data.Address = order.From
+			data.Counterparty = order.To
+			event = pushContract
+		case subscription.Target != order.To:
+			data.Address = order.From
+			data.Counterparty = order.To
+			event = pushSent
+		}
+
+		data.Label = labels[data.Counterparty]
+
+		if err := watcher.queue(session, subscription.UserID, data.Address, event, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
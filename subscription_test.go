@@ -0,0 +1,148 @@
+package orderservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/inwecrypto/neodb"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestContext gin context of a request authenticated as caller, anonymous if nil
+func newTestContext(caller *principal) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if caller != nil {
+		ctx.Set(principalKey, caller)
+	}
+
+	return ctx
+}
+
+func TestAuthorizeContractSubscription(t *testing.T) {
+	tests := []struct {
+		contractAdmin bool
+		caller        *principal
+		allowed       bool
+	}{
+		{false, nil, false},
+		{false, &principal{UserID: "alice"}, true},
+		{false, &principal{Admin: true}, true},
+		{true, nil, false},
+		{true, &principal{UserID: "alice"}, false},
+		{true, &principal{Admin: true}, true},
+	}
+
+	for _, test := range tests {
+		service := &HTTPServer{contractAdmin: test.contractAdmin}
+
+		err := service.authorizeContractSubscription(newTestContext(test.caller))
+
+		if test.allowed {
+			assert.NoError(t, err, "%+v", test)
+		} else if assert.Error(t, err, "%+v", test) {
+			assert.Equal(t, http.StatusForbidden, err.(*apiError).Status)
+		}
+	}
+}
+
+func TestAuthorizeBalance(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.Sync2(new(Subscription), new(neodb.Wallet)); err != nil {
+		t.Fatal(err)
+	}
+
+	const hidden = "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"
+	const owned = "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr"
+
+	db.Where("user_i_d = ?", "balance-test").Delete(new(Subscription))
+	db.Where("user_i_d = ?", "balance-test").Delete(new(neodb.Wallet))
+
+	_, err := db.Insert(
+		&Subscription{UserID: "balance-test", Kind: subscribeAddress, Target: hidden, HideBalance: true},
+		&Subscription{UserID: "balance-test", Kind: subscribeAddress, Target: owned, HideBalance: true},
+		&neodb.Wallet{UserID: "balance-test", Address: owned},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &HTTPServer{db: db}
+
+	// hidden to every caller but the admins, whoever subscribed
+	for _, caller := range []*principal{{UserID: "balance-test"}, {UserID: "other"}, nil} {
+		err = service.authorizeBalance(newTestContext(caller), hidden)
+
+		if assert.Error(t, err, "%+v", caller) {
+			assert.Equal(t, http.StatusForbidden, err.(*apiError).Status)
+		}
+	}
+
+	assert.NoError(t, service.authorizeBalance(newTestContext(&principal{Admin: true}), hidden))
+
+	// the wallet owner still sees it
+	assert.NoError(t, service.authorizeBalance(newTestContext(&principal{UserID: "balance-test"}), owned))
+
+	err = service.authorizeBalance(newTestContext(&principal{UserID: "other"}), owned)
+
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, err.(*apiError).Status)
+	}
+
+	assert.NoError(t, service.authorizeBalance(newTestContext(nil), "AXxSKZb5uUESD4QnkWCpZVCWG4hX4WLbRS"))
+}
+
+func TestSaveSubscriptionQuota(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.Sync2(new(Subscription)); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Where("user_i_d = ?", "quota-test").Delete(new(Subscription))
+
+	service := &HTTPServer{db: db, maxSubscriptions: 3}
+
+	targets := []string{
+		"AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y",
+		"AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		"AXxSKZb5uUESD4QnkWCpZVCWG4hX4WLbRS",
+		"AbGyNUoPsrf8iYVQ8XhwJmuJ8hBPB5ktFW",
+		"AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i",
+		"ATLcRcA6WdJKs9Z7HZx6QRz5uQdDQDf4fV",
+	}
+
+	var wg sync.WaitGroup
+	var created int32
+
+	for _, target := range targets {
+		wg.Add(1)
+
+		go func(target string) {
+			defer wg.Done()
+
+			ok, err := service.saveSubscription(&Subscription{UserID: "quota-test", Kind: subscribeAddress, Target: target})
+
+			if ok && err == nil {
+				atomic.AddInt32(&created, 1)
+			}
+		}(target)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(3), created)
+
+	count, err := db.Where("user_i_d = ?", "quota-test").Count(new(Subscription))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), count)
+	}
+}
@@ -0,0 +1,80 @@
+package orderservice
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/sling"
+	"github.com/inwecrypto/neo-order-service/neo"
+	"github.com/stretchr/testify/assert"
+)
+
+type alertRule struct {
+	ID        int64  `json:"id,omitempty"`
+	UserID    string `json:"userid,omitempty"`
+	Kind      string `json:"kind"`
+	Address   string `json:"address,omitempty"`
+	Asset     string `json:"asset,omitempty"`
+	Threshold string `json:"threshold,omitempty"`
+}
+
+func TestAlertRules(t *testing.T) {
+	var result alertRule
+	var errmsg interface{}
+
+	resp, err := sling.New().Post("http://localhost:8000/alerts/xxxxx").BodyJSON(&alertRule{
+		Kind:      "large",
+		Asset:     "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+		Threshold: "500",
+	}).Receive(&result, &errmsg)
+
+	if !assert.NoError(t, err) || !assert.Equal(t, http.StatusCreated, resp.StatusCode) {
+		return
+	}
+
+	assert.Equal(t, "xxxxx", result.UserID)
+	assert.NotZero(t, result.ID)
+
+	var rules []*alertRule
+
+	resp, err = sling.New().Get("http://localhost:8000/alerts/xxxxx").Receive(&rules, &errmsg)
+
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) {
+		assert.Contains(t, rules, &result)
+	}
+
+	path := fmt.Sprintf("http://localhost:8000/alerts/xxxxx/%d", result.ID)
+
+	resp, err = sling.New().Delete(path).Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err = sling.New().Delete(path).Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestInvalidAlertRules(t *testing.T) {
+	var errmsg interface{}
+
+	for _, rule := range []*alertRule{
+		{Kind: "huge"},
+		{Kind: "large", Asset: "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"},
+		{Kind: "large", Asset: "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", Threshold: "0"},
+		{Kind: "outgoing"},
+		{Kind: "outgoing", Address: "not an address"},
+		{Kind: "outgoing", Address: neo.PublicKeyAddress(&newWalletKey(t).PublicKey)}, // neither a wallet nor a subscription
+		{Kind: "counterparty", Threshold: "10"},
+	} {
+		resp, err := sling.New().Post("http://localhost:8000/alerts/xxxxx").BodyJSON(rule).Receive(nil, &errmsg)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, rule.Kind)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package orderservice
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/sling"
+	"github.com/stretchr/testify/assert"
+)
+
+type contact struct {
+	UserID  string `json:"userid,omitempty"`
+	Address string `json:"address"`
+	Label   string `json:"label"`
+}
+
+func TestContacts(t *testing.T) {
+	var result contact
+	var errmsg interface{}
+
+	resp, err := sling.New().Post("http://localhost:8000/contacts/xxxxx").BodyJSON(&contact{
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		Label:   "test",
+	}).Receive(&result, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Contains(t, []int{http.StatusCreated, http.StatusOK}, resp.StatusCode)
+		assert.Equal(t, "test", result.Label)
+	}
+
+	var contacts []*contact
+
+	resp, err = sling.New().Get("http://localhost:8000/contacts/xxxxx").Receive(&contacts, &errmsg)
+
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) {
+		assert.Contains(t, contacts, &contact{UserID: "xxxxx", Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Label: "test"})
+	}
+
+	var orders []struct {
+		From      string `json:"from"`
+		FromLabel string `json:"fromLabel"`
+	}
+
+	resp, err = sling.New().Get("http://localhost:8000/order/0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11?userid=xxxxx").Receive(&orders, &errmsg)
+
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) {
+		for _, order := range orders {
+			if order.From == "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr" {
+				assert.Equal(t, "test", order.FromLabel)
+			}
+		}
+	}
+
+	resp, err = sling.New().Delete("http://localhost:8000/contacts/xxxxx/AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr").Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestCreateContactInvalidLabel(t *testing.T) {
+	var errmsg struct {
+		Code  string `json:"code"`
+		Field string `json:"field"`
+	}
+
+	resp, err := sling.New().Post("http://localhost:8000/contacts/xxxxx").BodyJSON(&contact{
+		Address: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+	}).Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "label", errmsg.Field)
+	}
+}
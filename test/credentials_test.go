@@ -0,0 +1,70 @@
+package orderservice
+
+import (
+	"io"
+	"net/http"
+	"os"
+)
+
+// credentials of the integration tests, they must match the order.auth settings of the
+// service under test, see README.md
+var (
+	testAPIKeys = map[string]string{
+		"xxxxx":   getenv("NEO_ORDER_TEST_APIKEY", "test-user-key"),
+		"wallets": getenv("NEO_ORDER_TEST_WALLETS_APIKEY", "test-wallets-key"),
+	}
+	testAdminToken = getenv("NEO_ORDER_TEST_ADMIN_TOKEN", "test-admin-token")
+)
+
+func getenv(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// credentialsTransport authenticate the requests without credentials with the test api key
+// of user xxxxx
+type credentialsTransport struct {
+	base http.RoundTripper
+}
+
+func (transport *credentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("X-API-Key") != "" || req.Header.Get("X-Admin-Token") != "" || req.Header.Get("Authorization") != "" {
+		return transport.base.RoundTrip(req)
+	}
+
+	authenticated := *req
+	authenticated.Header = make(http.Header, len(req.Header)+1)
+
+	for name, values := range req.Header {
+		authenticated.Header[name] = values
+	}
+
+	authenticated.Header.Set("X-API-Key", testAPIKeys["xxxxx"])
+
+	return transport.base.RoundTrip(&authenticated)
+}
+
+func init() {
+	http.DefaultClient.Transport = &credentialsTransport{base: http.DefaultTransport}
+}
+
+// adminRequest create a request authenticated with the admin token, for the fixtures of
+// addresses user xxxxx can not register
+func adminRequest(method string, url string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	req.Header.Set("X-Admin-Token", testAdminToken)
+
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,72 @@
+package orderservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/sling"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateInvoice(t *testing.T) {
+	body, err := json.Marshal(map[string]string{
+		"address": "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		"asset":   "0x602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7",
+		"amount":  "1.50",
+		"memo":    "order-1001",
+	})
+
+	assert.NoError(t, err)
+
+	var invoice struct {
+		ID     string `json:"id"`
+		Amount string `json:"amount"`
+		Status string `json:"status"`
+		URI    string `json:"uri"`
+	}
+	var errmsg interface{}
+
+	resp, err := sling.New().Post("http://localhost:8000/invoice").
+		Body(bytes.NewReader(body)).Set("Content-Type", "application/json").Set("X-Admin-Token", testAdminToken).
+		Receive(&invoice, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "1.5", invoice.Amount)
+		assert.Equal(t, "open", invoice.Status)
+		assert.True(t, strings.HasPrefix(invoice.URI, "neo:AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr?"))
+		assert.Contains(t, invoice.URI, "asset=gas")
+	}
+
+	var fetched struct {
+		ID string `json:"id"`
+	}
+
+	resp, err = sling.New().Get("http://localhost:8000/invoice/"+invoice.ID).Receive(&fetched, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, invoice.ID, fetched.ID)
+	}
+}
+
+func TestCreateInvoiceInvalidAmount(t *testing.T) {
+	for _, amount := range []string{"0", "-1", "1e3", "0.000000001"} {
+		body, err := json.Marshal(map[string]string{
+			"address": "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+			"asset":   "0x602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7",
+			"amount":  amount,
+		})
+
+		assert.NoError(t, err)
+
+		resp, err := adminRequest(http.MethodPost, "http://localhost:8000/invoice", "application/json", bytes.NewReader(body))
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, amount)
+		}
+	}
+}
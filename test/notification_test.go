@@ -0,0 +1,42 @@
+package orderservice
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/sling"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListNotifications(t *testing.T) {
+	var page struct {
+		Notifications []struct {
+			ID     int64  `json:"id"`
+			UserID string `json:"userid"`
+			Status string `json:"status"`
+		} `json:"notifications"`
+		Next string `json:"next"`
+	}
+	var errmsg interface{}
+
+	resp, err := sling.New().Get("http://localhost:8000/notifications/xxxxx?limit=5").Receive(&page, &errmsg)
+
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) {
+		assert.True(t, len(page.Notifications) <= 5)
+
+		for i, notification := range page.Notifications {
+			assert.Equal(t, "xxxxx", notification.UserID)
+			assert.Contains(t, []string{"pending", "delivered", "failed"}, notification.Status)
+
+			if i > 0 {
+				assert.True(t, notification.ID < page.Notifications[i-1].ID)
+			}
+		}
+	}
+
+	resp, err = sling.New().Get("http://localhost:8000/notifications/xxxxx?status=sent").Receive(&page, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
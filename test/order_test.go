@@ -2,6 +2,7 @@ package orderservice
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -10,25 +11,32 @@ import (
 	"github.com/dghubble/sling"
 
 	"github.com/inwecrypto/neo-order-service/model"
+	"github.com/inwecrypto/neo-order-service/neo"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateWallet(t *testing.T) {
-	resp, err := http.Post("http://localhost:8000/wallet/xxxxx/test", "application/json", strings.NewReader("{}"))
+	resp, err := registerWallet(t, "xxxxx", newWalletKey(t))
 
 	if assert.NoError(t, err) {
-		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
 	}
-
 }
 
 func TestDeleteWallet(t *testing.T) {
+	key := newWalletKey(t)
+
+	resp, err := registerWallet(t, "xxxxx", key)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
 
-	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8000/wallet/xxxxx/test", nil)
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8000/wallet/xxxxx/"+neo.PublicKeyAddress(&key.PublicKey), nil)
 
 	assert.NoError(t, err)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err = http.DefaultClient.Do(req)
 
 	if assert.NoError(t, err) {
 		assert.Equal(t, 200, resp.StatusCode)
@@ -38,19 +46,19 @@ func TestDeleteWallet(t *testing.T) {
 func TestCreateOrder(t *testing.T) {
 
 	order, err := json.Marshal(&model.Order{
-		Tx:    "xxxxxx",
-		From:  "xxxxxxx",
-		To:    "xxxxxxx",
-		Asset: "xxxxxxxxxxx",
+		Tx:    "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+		From:  "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		To:    "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		Asset: "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
 		Value: "1",
 	})
 
 	assert.NoError(t, err)
 
-	resp, err := http.Post("http://localhost:8000/order", "application/json", bytes.NewReader(order))
+	resp, err := adminRequest(http.MethodPost, "http://localhost:8000/order", "application/json", bytes.NewReader(order))
 
 	if assert.NoError(t, err) {
-		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
 	}
 }
 
@@ -77,3 +85,333 @@ func TestListOrder(t *testing.T) {
 	assert.NotZero(t, len(orders))
 
 }
+
+func TestQueryOrders(t *testing.T) {
+	var page struct {
+		Orders []*model.Order `json:"orders"`
+		Next   string         `json:"next"`
+		Total  int64          `json:"total"`
+	}
+	var errmsg interface{}
+
+	_, err := sling.New().Get("http://localhost:8000/orders?address=AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr&limit=1&count=true").Receive(&page, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.NotZero(t, page.Total)
+		assert.Len(t, page.Orders, 1)
+	}
+
+	if page.Next == "" {
+		return
+	}
+
+	var next struct {
+		Orders []*model.Order `json:"orders"`
+	}
+
+	_, err = sling.New().Get("http://localhost:8000/orders?address=AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr&limit=1&cursor="+page.Next).Receive(&next, &errmsg)
+
+	if assert.NoError(t, err) && assert.Len(t, next.Orders, 1) {
+		assert.NotEqual(t, page.Orders[0].Tx, next.Orders[0].Tx)
+	}
+}
+
+func TestListAllAssetOrders(t *testing.T) {
+	var orders []map[string]interface{}
+	var errmsg interface{}
+
+	_, err := sling.New().Get("http://localhost:8000/orders/AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr/all/0/10").Receive(&orders, &errmsg)
+
+	if assert.NoError(t, err) && assert.NotZero(t, len(orders)) {
+		for _, order := range orders {
+			assert.NotEmpty(t, order["assetName"])
+		}
+	}
+}
+
+func TestListAssets(t *testing.T) {
+	var assets []map[string]interface{}
+	var errmsg interface{}
+
+	_, err := sling.New().Get("http://localhost:8000/assets").Receive(&assets, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.NotZero(t, len(assets))
+	}
+}
+
+// the default test api key is not an admin key
+func TestCreateAssetWithoutAdminToken(t *testing.T) {
+	resp, err := http.Post("http://localhost:8000/asset", "application/json", strings.NewReader(`{"asset":"0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9","symbol":"RPX","decimals":8,"type":"nep5"}`))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestCreateOrderInvalidValue(t *testing.T) {
+	for _, value := range []string{"-1", "1e8", "0.000000001", "abc"} {
+		order, err := json.Marshal(&model.Order{
+			Tx:    "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11",
+			From:  "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+			To:    "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+			Asset: "0x602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7",
+			Value: value,
+		})
+
+		assert.NoError(t, err)
+
+		resp, err := http.Post("http://localhost:8000/order", "application/json", bytes.NewReader(order))
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, value)
+		}
+	}
+}
+
+func TestCreateWalletInvalidAddress(t *testing.T) {
+	for _, address := range []string{"test", "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsR", "1BoatSLRHtKNngkdXEeobR76b53LETtpyT"} {
+		resp, err := http.Post("http://localhost:8000/wallet/xxxxx/"+address, "application/json", strings.NewReader("{}"))
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, address)
+		}
+	}
+}
+
+func TestCreateOrderInvalidFields(t *testing.T) {
+	for _, order := range []*model.Order{
+		{Tx: "xxxxxx", From: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", To: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Asset: "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", Value: "1"},
+		{Tx: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11", From: "xxxxxxx", To: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Asset: "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", Value: "1"},
+		{Tx: "0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11", From: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", To: "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", Asset: "xxxxxxxxxxx", Value: "1"},
+	} {
+		data, err := json.Marshal(order)
+
+		assert.NoError(t, err)
+
+		resp, err := http.Post("http://localhost:8000/order", "application/json", bytes.NewReader(data))
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+func TestDeleteUnknownWallet(t *testing.T) {
+	resp, err := adminRequest(http.MethodDelete, "http://localhost:8000/wallet/unknown-user/AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", "", nil)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	req, err := sling.New().Get("http://localhost:8000/order/xxxxxx").Set("X-Request-ID", "test-request-id").Request()
+
+	assert.NoError(t, err)
+
+	var errmsg struct {
+		Code      string `json:"code"`
+		Error     string `json:"error"`
+		Field     string `json:"field"`
+		RequestID string `json:"requestId"`
+	}
+
+	resp, err := sling.New().Do(req, nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_parameter", errmsg.Code)
+		assert.Equal(t, "tx", errmsg.Field)
+		assert.Equal(t, "test-request-id", errmsg.RequestID)
+	}
+}
+
+func TestCreateOrderWithContext(t *testing.T) {
+	order, err := json.Marshal(map[string]interface{}{
+		"tx":      "0x5d2c0a4e1e3ac3c8a1d67d3c3bdf7a8f1e2f6a1b9c3d4e5f60718293a4b5c6d7",
+		"from":    "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		"to":      "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		"asset":   "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+		"value":   "1",
+		"context": map[string]interface{}{"merchantId": "m-1001", "memo": "coffee"},
+	})
+
+	assert.NoError(t, err)
+
+	resp, err := adminRequest(http.MethodPost, "http://localhost:8000/order", "application/json", bytes.NewReader(order))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	var page struct {
+		Orders []struct {
+			Tx      string                 `json:"tx"`
+			Context map[string]interface{} `json:"context"`
+		} `json:"orders"`
+	}
+	var errmsg interface{}
+
+	_, err = sling.New().Get("http://localhost:8000/orders?address=AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr&context.merchantId=m-1001").Receive(&page, &errmsg)
+
+	if assert.NoError(t, err) && assert.NotZero(t, len(page.Orders)) {
+		for _, order := range page.Orders {
+			assert.Equal(t, "m-1001", order.Context["merchantId"])
+		}
+	}
+}
+
+func TestCreateOrderInvalidContext(t *testing.T) {
+	order, err := json.Marshal(map[string]interface{}{
+		"tx":      "0x5d2c0a4e1e3ac3c8a1d67d3c3bdf7a8f1e2f6a1b9c3d4e5f60718293a4b5c6d8",
+		"from":    "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		"to":      "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr",
+		"asset":   "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+		"value":   "1",
+		"context": []string{"not", "an", "object"},
+	})
+
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://localhost:8000/order", "application/json", bytes.NewReader(order))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestFeeSummary(t *testing.T) {
+	var summary struct {
+		Address string `json:"address"`
+		Fee     string `json:"fee"`
+		Txs     int    `json:"txs"`
+	}
+	var errmsg interface{}
+
+	resp, err := sling.New().Get("http://localhost:8000/fees/AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr").Receive(&summary, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", summary.Address)
+		assert.NotEmpty(t, summary.Fee)
+	}
+}
+
+func TestQueryTxView(t *testing.T) {
+	var page struct {
+		Txs []struct {
+			Tx        string `json:"tx"`
+			Address   string `json:"address"`
+			Direction string `json:"direction"`
+			Transfers []struct {
+				Asset string `json:"asset"`
+				Net   string `json:"net"`
+			} `json:"transfers"`
+		} `json:"txs"`
+	}
+	var errmsg interface{}
+
+	resp, err := sling.New().Get("http://localhost:8000/orders?address=AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr&view=tx").Receive(&page, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		txs := make(map[string]bool)
+
+		for _, tx := range page.Txs {
+			assert.False(t, txs[tx.Tx], "tx %s listed twice", tx.Tx)
+			txs[tx.Tx] = true
+
+			assert.Equal(t, "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", tx.Address)
+			assert.Contains(t, []string{"incoming", "outgoing", "self"}, tx.Direction)
+			assert.NotEmpty(t, tx.Transfers)
+		}
+	}
+}
+
+func TestGetOrderTxView(t *testing.T) {
+	var views []struct {
+		Tx        string `json:"tx"`
+		Direction string `json:"direction"`
+	}
+	var errmsg interface{}
+
+	resp, err := sling.New().Get("http://localhost:8000/order/0x67905b068cde98d0450168bf6f8feac5eac390073a97cb660dadb056fa31ca11?view=tx&address=AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr").Receive(&views, &errmsg)
+
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) && assert.Len(t, views, 1) {
+		// the order created by TestCreateOrder sends to the address itself
+		assert.Equal(t, "self", views[0].Direction)
+	}
+}
+
+func TestExportOrders(t *testing.T) {
+	resp, err := http.Get("http://localhost:8000/export/AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr?status=all")
+
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+
+		records, err := csv.NewReader(resp.Body).ReadAll()
+
+		if assert.NoError(t, err) && assert.NotEmpty(t, records) {
+			assert.Equal(t, []string{
+				"tx", "block", "createTime", "confirmTime", "status", "direction",
+				"from", "to", "asset", "symbol", "value", "fee",
+			}, records[0])
+		}
+	}
+}
+
+func TestExportOrdersJSONL(t *testing.T) {
+	resp, err := http.Get("http://localhost:8000/export/AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr?format=jsonl&status=all")
+
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		decoder := json.NewDecoder(resp.Body)
+
+		for decoder.More() {
+			var row map[string]interface{}
+
+			if !assert.NoError(t, decoder.Decode(&row)) {
+				break
+			}
+
+			assert.Contains(t, row, "tx")
+			assert.Contains(t, row, "direction")
+		}
+	}
+}
+
+func TestGetAddressStats(t *testing.T) {
+	var stats struct {
+		Address string `json:"address"`
+		Assets  []struct {
+			Asset string `json:"asset"`
+			Txs   int64  `json:"txs"`
+		} `json:"assets"`
+		Daily []struct {
+			Day string `json:"day"`
+		} `json:"daily"`
+	}
+	var errmsg interface{}
+
+	resp, err := sling.New().Get("http://localhost:8000/stats/AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr").Receive(&stats, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "AMpupnF6QweQXLfCtF4dR45FDdKbTXkLsr", stats.Address)
+
+		for _, asset := range stats.Assets {
+			assert.True(t, asset.Txs > 0, "asset %s has no txs", asset.Asset)
+		}
+
+		assert.True(t, len(stats.Daily) <= 30*len(stats.Assets))
+	}
+}
@@ -0,0 +1,97 @@
+package orderservice
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/sling"
+	"github.com/stretchr/testify/assert"
+)
+
+type subscription struct {
+	UserID      string `json:"userid,omitempty"`
+	Kind        string `json:"kind"`
+	Target      string `json:"target"`
+	Label       string `json:"label,omitempty"`
+	HideBalance bool   `json:"hideBalance"`
+}
+
+func TestSubscriptions(t *testing.T) {
+	var result subscription
+	var errmsg interface{}
+
+	resp, err := sling.New().Post("http://localhost:8000/subscriptions/xxxxx").BodyJSON(&subscription{
+		Kind:        "address",
+		Target:      "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y",
+		Label:       "exchange",
+		HideBalance: true,
+	}).Receive(&result, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Contains(t, []int{http.StatusCreated, http.StatusOK}, resp.StatusCode)
+		assert.Equal(t, "xxxxx", result.UserID)
+	}
+
+	// contract subscriptions are reserved to admins
+	resp, err = sling.New().Post("http://localhost:8000/subscriptions/xxxxx").BodyJSON(&subscription{
+		Kind:   "contract",
+		Target: "ECC6B20D3CCAC1EE9EF109AF5A7CDB85706B1DF9",
+	}).Receive(&result, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+
+	var subscriptions []*subscription
+
+	resp, err = sling.New().Get("http://localhost:8000/subscriptions/xxxxx").Receive(&subscriptions, &errmsg)
+
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) {
+		assert.Contains(t, subscriptions, &subscription{
+			UserID: "xxxxx", Kind: "address", Target: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y", Label: "exchange", HideBalance: true,
+		})
+	}
+
+	// the test api key authenticates user xxxxx, who watches the address with hideBalance
+	resp, err = sling.New().Get("http://localhost:8000/stats/AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y").Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+
+	resp, err = sling.New().Get("http://localhost:8000/stats/AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y").
+		Set("X-Admin-Token", testAdminToken).Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err = sling.New().Get("http://localhost:8000/orders?address=AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y&userid=xxxxx").Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err = sling.New().Delete("http://localhost:8000/subscriptions/xxxxx/AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y").Receive(nil, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestInvalidSubscriptions(t *testing.T) {
+	var errmsg interface{}
+
+	for _, body := range []*subscription{
+		{Kind: "token", Target: "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"},
+		{Kind: "address", Target: "not an address"},
+		{Kind: "contract", Target: "0x1234"},
+		{Kind: "contract", Target: "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9", HideBalance: true},
+	} {
+		resp, err := sling.New().Post("http://localhost:8000/subscriptions/xxxxx").BodyJSON(body).Receive(nil, &errmsg)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body.Target)
+		}
+	}
+}
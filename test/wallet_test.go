@@ -0,0 +1,348 @@
+package orderservice
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/sling"
+	"github.com/inwecrypto/neo-order-service/neo"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWalletKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	assert.NoError(t, err)
+
+	return key
+}
+
+func getChallenge(t *testing.T, userid string, address string) string {
+	var challenge struct {
+		Nonce string `json:"nonce"`
+	}
+	var errmsg interface{}
+
+	query := url.Values{"userid": {userid}, "address": {address}}
+
+	_, err := sling.New().Get("http://localhost:8000/challenge?"+query.Encode()).
+		Set("X-API-Key", testAPIKeys[userid]).Receive(&challenge, &errmsg)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, challenge.Nonce)
+
+	return challenge.Nonce
+}
+
+func signNonce(t *testing.T, key *ecdsa.PrivateKey, nonce string) string {
+	digest := sha256.Sum256([]byte(nonce))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+
+	assert.NoError(t, err)
+
+	signature := make([]byte, 64)
+	copy(signature[32-len(r.Bytes()):32], r.Bytes())
+	copy(signature[64-len(s.Bytes()):], s.Bytes())
+
+	return hex.EncodeToString(signature)
+}
+
+// registerWallet register the address of key to userid with the challenge/response flow
+func registerWallet(t *testing.T, userid string, key *ecdsa.PrivateKey) (*http.Response, error) {
+	address := neo.PublicKeyAddress(&key.PublicKey)
+
+	nonce := getChallenge(t, userid, address)
+
+	proof, err := json.Marshal(map[string]string{
+		"nonce":     nonce,
+		"publicKey": hex.EncodeToString(neo.CompressPublicKey(&key.PublicKey)),
+		"signature": signNonce(t, key, nonce),
+	})
+
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8000/wallet/"+userid+"/"+address, bytes.NewReader(proof))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", testAPIKeys[userid])
+
+	return http.DefaultClient.Do(req)
+}
+
+func TestCreateWalletWithoutProof(t *testing.T) {
+	address := neo.PublicKeyAddress(&newWalletKey(t).PublicKey)
+
+	resp, err := http.Post("http://localhost:8000/wallet/xxxxx/"+address, "application/json", bytes.NewReader([]byte("{}")))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestCreateWalletWithOtherKey(t *testing.T) {
+	key := newWalletKey(t)
+	other := newWalletKey(t)
+
+	address := neo.PublicKeyAddress(&key.PublicKey)
+
+	nonce := getChallenge(t, "xxxxx", address)
+
+	proof, err := json.Marshal(map[string]string{
+		"nonce":     nonce,
+		"publicKey": hex.EncodeToString(neo.CompressPublicKey(&other.PublicKey)),
+		"signature": signNonce(t, other, nonce),
+	})
+
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://localhost:8000/wallet/xxxxx/"+address, "application/json", bytes.NewReader(proof))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// the rejected proof does not consume the nonce
+	proof, err = json.Marshal(map[string]string{
+		"nonce":     nonce,
+		"publicKey": hex.EncodeToString(neo.CompressPublicKey(&key.PublicKey)),
+		"signature": signNonce(t, key, nonce),
+	})
+
+	assert.NoError(t, err)
+
+	resp, err = http.Post("http://localhost:8000/wallet/xxxxx/"+address, "application/json", bytes.NewReader(proof))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+}
+
+func TestChallengeSingleUse(t *testing.T) {
+	key := newWalletKey(t)
+	address := neo.PublicKeyAddress(&key.PublicKey)
+
+	nonce := getChallenge(t, "xxxxx", address)
+
+	proof, err := json.Marshal(map[string]string{
+		"nonce":     nonce,
+		"publicKey": hex.EncodeToString(neo.CompressPublicKey(&key.PublicKey)),
+		"signature": signNonce(t, key, nonce),
+	})
+
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://localhost:8000/wallet/xxxxx/"+address, "application/json", bytes.NewReader(proof))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	// the admin may register for any user, the nonce is still bound to xxxxx
+	resp, err = adminRequest(http.MethodPost, "http://localhost:8000/wallet/yyyyy/"+address, "application/json", bytes.NewReader(proof))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestListAndUpdateWallets(t *testing.T) {
+	key := newWalletKey(t)
+	address := neo.PublicKeyAddress(&key.PublicKey)
+
+	resp, err := registerWallet(t, "wallets", key)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	update, err := json.Marshal(map[string]interface{}{
+		"label": "cold wallet",
+		"notification": map[string]interface{}{
+			"assets":   []string{"0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"},
+			"minValue": "10",
+		},
+	})
+
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8000/wallet/wallets/"+address, bytes.NewReader(update))
+
+	assert.NoError(t, err)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", testAPIKeys["wallets"])
+
+	resp, err = http.DefaultClient.Do(req)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	var wallets []struct {
+		Address      string `json:"address"`
+		Label        string `json:"label"`
+		Notification struct {
+			Muted    bool     `json:"muted"`
+			Assets   []string `json:"assets"`
+			MinValue string   `json:"minValue"`
+		} `json:"notification"`
+	}
+	var errmsg interface{}
+
+	_, err = sling.New().Get("http://localhost:8000/wallets/wallets").Set("X-API-Key", testAPIKeys["wallets"]).Receive(&wallets, &errmsg)
+
+	if assert.NoError(t, err) {
+		var found bool
+
+		for _, wallet := range wallets {
+			if wallet.Address == address {
+				found = true
+				assert.Equal(t, "cold wallet", wallet.Label)
+				assert.Equal(t, "10", wallet.Notification.MinValue)
+				assert.Len(t, wallet.Notification.Assets, 1)
+			}
+		}
+
+		assert.True(t, found)
+	}
+
+	req, err = http.NewRequest(http.MethodDelete, "http://localhost:8000/wallets/wallets", nil)
+
+	assert.NoError(t, err)
+
+	req.Header.Set("X-API-Key", testAPIKeys["wallets"])
+
+	resp, err = http.DefaultClient.Do(req)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	wallets = nil
+
+	_, err = sling.New().Get("http://localhost:8000/wallets/wallets").Set("X-API-Key", testAPIKeys["wallets"]).Receive(&wallets, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Empty(t, wallets)
+	}
+}
+
+func TestUpdateWalletInvalidMinValue(t *testing.T) {
+	address := neo.PublicKeyAddress(&newWalletKey(t).PublicKey)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8000/wallet/xxxxx/"+address,
+		bytes.NewReader([]byte(`{"notification":{"minValue":"-1"}}`)))
+
+	assert.NoError(t, err)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestWalletBackfill(t *testing.T) {
+	key := newWalletKey(t)
+	address := neo.PublicKeyAddress(&key.PublicKey)
+
+	resp, err := registerWallet(t, "xxxxx", key)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	var backfill struct {
+		Address string `json:"address"`
+		Status  string `json:"status"`
+	}
+	var errmsg interface{}
+
+	resp, err = sling.New().Get("http://localhost:8000/wallet/xxxxx/"+address+"/backfill").Receive(&backfill, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, address, backfill.Address)
+		assert.Contains(t, []string{"running", "done"}, backfill.Status)
+	}
+
+	resp, err = sling.New().Get("http://localhost:8000/wallet/yyyyy/"+address+"/backfill").
+		Set("X-Admin-Token", testAdminToken).Receive(&backfill, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestPushPreview(t *testing.T) {
+	var previews []struct {
+		Locale  string `json:"locale"`
+		Event   string `json:"event"`
+		Message string `json:"message"`
+	}
+	var errmsg interface{}
+
+	resp, err := sling.New().Get("http://localhost:8000/push/preview?locale=zh-cn&event=received").Receive(&previews, &errmsg)
+
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) && assert.Len(t, previews, 1) {
+		assert.Equal(t, "zh-CN", previews[0].Locale)
+		assert.Contains(t, previews[0].Message, "收到")
+	}
+
+	resp, err = sling.New().Get("http://localhost:8000/push/preview?locale=fr-FR").Receive(&previews, &errmsg)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestUpdateWalletInvalidChannel(t *testing.T) {
+	address := neo.PublicKeyAddress(&newWalletKey(t).PublicKey)
+
+	for _, body := range []string{
+		`{"channel":"sms","target":"+8613800000000"}`,
+		`{"channel":"email","target":"not an email"}`,
+		`{"channel":"push","target":"alice@example.com"}`,
+	} {
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8000/wallet/xxxxx/"+address, bytes.NewReader([]byte(body)))
+
+		assert.NoError(t, err)
+
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestChannelToken(t *testing.T) {
+	for _, body := range []string{
+		`{"channel":"push","target":""}`,
+		`{"channel":"sms","target":"+8613800000000"}`,
+		`{"channel":"email","target":"not an email"}`,
+	} {
+		resp, err := http.Post("http://localhost:8000/channel/xxxxx", "application/json", bytes.NewReader([]byte(body)))
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package orderservice
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/inwecrypto/neodb"
+)
+
+// orders list views
+const (
+	orderViewOrder = "order"
+	orderViewTx    = "tx"
+)
+
+// tx directions relative to the viewing address
+const (
+	txIncoming = "incoming"
+	txOutgoing = "outgoing"
+	txSelf     = "self"
+)
+
+// TxTransfer net transfer of one asset in a tx, relative to the viewing address
+type TxTransfer struct {
+	Asset          string   `json:"asset"`
+	AssetName      string   `json:"assetName,omitempty"`
+	Received       string   `json:"received"`         // from other addresses
+	Sent           string   `json:"sent"`             // to other addresses, change excluded
+	Change         string   `json:"change,omitempty"` // sent back to the address itself
+	Net            string   `json:"net"`              // received minus sent
+	Counterparties []string `json:"counterparties,omitempty"`
+}
+
+// TxView orders of one tx grouped and netted for one address
+type TxView struct {
+	Tx          string        `json:"tx"`
+	Address     string        `json:"address"`
+	Direction   string        `json:"direction"`
+	Transfers   []*TxTransfer `json:"transfers"`
+	Fee         string        `json:"fee,omitempty"`
+	CreateTime  string        `json:"createTime"`
+	ConfirmTime string        `json:"confirmTime"`
+}
+
+// TxPage one page of the orders list api in tx view
+type TxPage struct {
+	Txs   []*TxView `json:"txs"`
+	Next  string    `json:"next,omitempty"`
+	Total *int64    `json:"total,omitempty"` // total txs matching the filters, only when count=true
+}
+
+type txTotals struct {
+	received       Amount
+	sent           Amount
+	change         Amount
+	counterparties map[string]bool
+}
+
+// newTxView group the orders of tx, all of the same tx, for address
+func (service *HTTPServer) newTxView(address string, torders []*neodb.Order, fees map[string]string) (*TxView, error) {
+	first := torders[0]
+
+	view := &TxView{
+		Tx:         first.TX,
+		Address:    address,
+		Transfers:  make([]*TxTransfer, 0),
+		Fee:        fees[first.TX],
+		CreateTime: first.CreateTime.Format(time.RFC3339Nano),
+	}
+
+	if first.ConfirmTime != nil {
+		view.ConfirmTime = first.ConfirmTime.Format(time.RFC3339Nano)
+	}
+
+	totals := make(map[string]*txTotals)
+	assets := make([]string, 0)
+
+	for _, torder := range torders {
+		if torder.From != address && torder.To != address {
+			continue
+		}
+
+		value, err := service.assets.parseValue(torder.Asset, torder.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		total, ok := totals[torder.Asset]
+
+		if !ok {
+			zero := Amount{Decimals: value.Decimals}
+
+			total = &txTotals{received: zero, sent: zero, change: zero, counterparties: make(map[string]bool)}
+			totals[torder.Asset] = total
+			assets = append(assets, torder.Asset)
+		}
+
+		switch {
+		case torder.From == address && torder.To == address:
+			total.change, err = total.change.Add(value)
+		case torder.To == address:
+			total.received, err = total.received.Add(value)
+			total.counterparties[torder.From] = true
+		default:
+			total.sent, err = total.sent.Add(value)
+			total.counterparties[torder.To] = true
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("tx %s has no transfer of %s", first.TX, address)
+	}
+
+	sort.Strings(assets)
+
+	view.Direction = txSelf
+
+	for _, asset := range assets {
+		total := totals[asset]
+
+		net, err := total.received.Sub(total.sent)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transfer := &TxTransfer{
+			Asset:     asset,
+			AssetName: service.assets.name(asset),
+			Received:  total.received.String(),
+			Sent:      total.sent.String(),
+			Net:       net.String(),
+		}
+
+		if total.change.IsPositive() {
+			transfer.Change = total.change.String()
+		}
+
+		for counterparty := range total.counterparties {
+			transfer.Counterparties = append(transfer.Counterparties, counterparty)
+		}
+
+		sort.Strings(transfer.Counterparties)
+
+		view.Transfers = append(view.Transfers, transfer)
+
+		if total.sent.IsPositive() {
+			view.Direction = txOutgoing
+		} else if total.received.IsPositive() && view.Direction == txSelf {
+			view.Direction = txIncoming
+		}
+	}
+
+	return view, nil
+}
+
+// getTxViews the views of tx for address, or for every address taking part in it if address is empty
+func (service *HTTPServer) getTxViews(tx string, address string) ([]*TxView, error) {
+	torders := make([]*neodb.Order, 0)
+
+	if err := service.db.Where("t_x = ?", tx).Asc("id").Find(&torders); err != nil {
+		return nil, err
+	}
+
+	if len(torders) == 0 {
+		return nil, errNotFound("order %s not found", tx)
+	}
+
+	addresses := []string{address}
+
+	if address == "" {
+		parties := make(map[string]bool)
+
+		for _, torder := range torders {
+			parties[torder.From] = true
+			parties[torder.To] = true
+		}
+
+		addresses = addresses[:0]
+
+		for party := range parties {
+			addresses = append(addresses, party)
+		}
+
+		sort.Strings(addresses)
+	}
+
+	fees, err := service.txFees(torders)
+
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]*TxView, 0, len(addresses))
+
+	for _, party := range addresses {
+		view, err := service.newTxView(party, torders, fees)
+
+		if err != nil {
+			if address != "" {
+				return nil, errNotFound("order %s of %s not found", tx, address)
+			}
+
+			return nil, err
+		}
+
+		views = append(views, view)
+	}
+
+	return views, nil
+}
+
+// txGroup keyset of one tx in the tx view pagination, the latest order of the tx
+type txGroup struct {
+	TX         string    `xorm:"t_x"`
+	CreateTime time.Time `xorm:"create_time"`
+	ID         int64     `xorm:"id"`
+}
+
+// queryTxs query the orders grouped by tx, a page holds limit txs with all their orders
+func (service *HTTPServer) queryTxs(query *orderQuery) (*TxPage, error) {
+
+	service.DebugF("query txs %+v", query)
+
+	session := query.filter(service.db.NewSession(), service.db.DatabaseTZ)
+
+	defer session.Close()
+
+	session = session.Table(new(neodb.Order)).
+		Select("t_x, max(create_time) as create_time, max(id) as id").
+		GroupBy("t_x")
+
+	if query.Cursor != nil {
+		// having takes no args, the cursor values are formatted by the server
+		createTime := formatDBTime(query.Cursor.CreateTime, service.db.DatabaseTZ)
+
+		session = session.Having(fmt.Sprintf(
+			"(max(create_time) < '%s' or (max(create_time) = '%s' and max(id) < %d))",
+			createTime, createTime, query.Cursor.ID,
+		))
+	}
+
+	groups := make([]*txGroup, 0)
+
+	err := session.
+		Desc("create_time", "id").
+		Limit(query.Limit + 1).
+		Find(&groups)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TxPage{
+		Txs: make([]*TxView, 0),
+	}
+
+	if len(groups) > query.Limit {
+		groups = groups[:query.Limit]
+
+		last := groups[len(groups)-1]
+
+		page.Next = (&orderCursor{CreateTime: last.CreateTime, ID: last.ID}).String()
+	}
+
+	if len(groups) > 0 {
+		txs := make([]string, 0, len(groups))
+
+		for _, group := range groups {
+			txs = append(txs, group.TX)
+		}
+
+		ordersSession := query.filter(service.db.NewSession(), service.db.DatabaseTZ)
+
+		defer ordersSession.Close()
+
+		torders := make([]*neodb.Order, 0)
+
+		if err := ordersSession.In("t_x", txs).Asc("id").Find(&torders); err != nil {
+			return nil, err
+		}
+
+		fees, err := service.txFees(torders)
+
+		if err != nil {
+			return nil, err
+		}
+
+		txOrders := make(map[string][]*neodb.Order)
+
+		for _, torder := range torders {
+			txOrders[torder.TX] = append(txOrders[torder.TX], torder)
+		}
+
+		for _, group := range groups {
+			view, err := service.newTxView(query.Address, txOrders[group.TX], fees)
+
+			if err != nil {
+				return nil, err
+			}
+
+			page.Txs = append(page.Txs, view)
+		}
+	}
+
+	if query.Count {
+		countSession := query.filter(service.db.NewSession(), service.db.DatabaseTZ)
+
+		defer countSession.Close()
+
+		total, err := countSession.Select("count(distinct t_x)").Count(new(neodb.Order))
+
+		if err != nil {
+			return nil, err
+		}
+
+		page.Total = &total
+	}
+
+	return page, nil
+}
@@ -2,9 +2,9 @@ package orderservice
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/denverdino/aliyungo/push"
 	"github.com/dynamicgo/config"
 	"github.com/dynamicgo/slf4go"
 	"github.com/go-xorm/xorm"
@@ -18,35 +18,27 @@ const (
 	gasAsset = "0x602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7"
 )
 
-var assetNames = map[string]string{
-	neoAsset: "NEO",
-	gasAsset: "NEO GAS",
-}
-
-func assetName(id string) string {
-	name, ok := assetNames[id]
-
-	if !ok {
-		name = "unknown asset"
-	}
-
-	return name
-}
-
-type pushMessage struct {
-	message string
-	id      string
-}
+// nep5 token script hashes
+const (
+	rpxAsset = "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+	dbcAsset = "0xb951ecbbc5fe37a9c280a76cb0ce0014827294cf"
+	qlcAsset = "0x0d821bd7b6d53f5c2b40e217c6defc8bbe896cf5"
+	tncAsset = "0x08e8c4400f1af2c20c28e0018f29535eb85d15b6"
+	ontAsset = "0xceab719b8baa2310f232ee0d277c061704541cfb"
+)
 
 // TxWatcher tx event watcher
 type TxWatcher struct {
 	mq gomq.Consumer
 	db *xorm.Engine
 	slf4go.Logger
-	pushClient   *push.Client
-	appkey       int64
-	pushChan     chan *pushMessage
-	pushDuration time.Duration
+	assets      *assetRegistry
+	labels      *labelBook
+	templates   *pushTemplates
+	dispatcher  *dispatcher
+	prices      *priceRecorder
+	nep5Topic   string
+	expireCheck time.Duration
 }
 
 // NewTxWatcher .
@@ -71,30 +63,77 @@ func NewTxWatcher(conf *config.Config) (*TxWatcher, error) {
 			username, password, host, scheme, port,
 		),
 	)
-	client := push.NewClient(
-		conf.GetString("nos.push.user", "xxxx"),
-		conf.GetString("nos.push.password", "xxxxx"),
-	)
+	if err != nil {
+		return nil, err
+	}
+
+	assets := newAssetRegistry(conf, db)
+
+	if err := assets.refresh(); err != nil {
+		return nil, err
+	}
+
+	labels, err := newLabelBook(conf, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := newPushTemplates(conf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dispatcher, err := newDispatcher(conf, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prices, err := newPriceRecorder(conf, db, assets)
+
+	if err != nil {
+		return nil, err
+	}
 
 	return &TxWatcher{
-		mq:           mq,
-		db:           db,
-		Logger:       slf4go.Get("txwatcher"),
-		pushClient:   client,
-		appkey:       conf.GetInt64("nos.push.appkey", 0),
-		pushChan:     make(chan *pushMessage, 100),
-		pushDuration: conf.GetDuration("nos.push.duration", time.Second*2),
+		mq:          mq,
+		db:          db,
+		Logger:      slf4go.Get("txwatcher"),
+		assets:      assets,
+		labels:      labels,
+		templates:   templates,
+		dispatcher:  dispatcher,
+		prices:      prices,
+		nep5Topic:   conf.GetString("order.nep5.topic", "neo-nep5-tx"),
+		expireCheck: conf.GetDuration("order.invoice.expirecheck", time.Minute),
 	}, nil
 }
 
 // Run run watcher
 func (watcher *TxWatcher) Run() {
 
+	go watcher.dispatcher.run()
+	go watcher.runExpire()
+
+	if watcher.prices != nil {
+		go watcher.prices.run()
+	}
+
 	for {
 		select {
 		case message, ok := <-watcher.mq.Messages():
 			if ok {
-				if err := watcher.confirm(string(message.Key())); err != nil {
+				var err error
+
+				if message.Topic() == watcher.nep5Topic {
+					err = watcher.confirmNEP5(string(message.Key()))
+				} else {
+					err = watcher.confirm(string(message.Key()))
+				}
+
+				if err != nil {
 					watcher.ErrorF("process tx confirm error,%s", err)
 				}
 
@@ -119,59 +158,302 @@ func (watcher *TxWatcher) confirm(txid string) error {
 		return err
 	}
 
+	return watcher.confirmTxs(txid, neoTxs)
+}
+
+// confirmTxs confirm the pending orders of tx or create orders for the tracked transfers
+// of wallets, invoices, alerts and subscriptions, the orders are scoped to the assets of neoTxs
+// so utxo and nep5 transfers of the same tx are handled independently
+func (watcher *TxWatcher) confirmTxs(txid string, neoTxs []*neodb.Tx) error {
 	if len(neoTxs) == 0 {
 		watcher.WarnF("handle tx %s -- not found", txid)
 		return nil
 	}
 
-	order := new(neodb.Order)
+	var assets []string
 
-	order.ConfirmTime = &neoTxs[0].CreateTime
-	order.Block = int64(neoTxs[0].Block)
+	for _, tx := range neoTxs {
+		assets = append(assets, tx.Asset)
+	}
 
-	updated, err := watcher.db.Where("t_x = ?", txid).Cols("confirm_time", "block").Update(order)
+	orders, err := watcher.saveOrders(txid, assets, neoTxs)
 
-	if err != nil {
+	if err != nil || len(orders) == 0 {
 		return err
 	}
 
-	if updated != 0 {
-		watcher.DebugF("updated orders(%d) for tx %s", updated, txid)
-		return nil
+	return watcher.confirmed(orders)
+}
+
+// saveOrders confirm or create the orders of tx and queue their notifications in one
+// transaction, returns the confirmed orders reloaded with their ids and contexts. A tx
+// delivered again whose orders are all confirmed already returns them without notifying
+func (watcher *TxWatcher) saveOrders(txid string, assets []string, neoTxs []*neodb.Tx) (orders []*neodb.Order, err error) {
+	session := watcher.db.NewSession()
+
+	defer session.Close()
+
+	if err = session.Begin(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		}
+	}()
+
+	args := []interface{}{
+		formatDBTime(neoTxs[0].CreateTime, watcher.db.DatabaseTZ), int64(neoTxs[0].Block), txid,
 	}
 
-	var orders []*neodb.Order
-	wallet := new(neodb.Wallet)
+	for _, asset := range assets {
+		args = append(args, asset)
+	}
 
-	for _, tx := range neoTxs {
+	// only the pending orders are confirmed, concurrent or replayed deliveries of tx update nothing
+	err = session.SQL(
+		`UPDATE neo_order SET confirm_time = ?, block = ? WHERE t_x = ? and asset IN (?`+
+			strings.Repeat(", ?", len(assets)-1)+`) and confirm_time IS NULL RETURNING *`,
+		args...,
+	).Find(&orders)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the orders were created through the api before the tx was confirmed
+	created := len(orders) != 0
+
+	if created {
+		watcher.DebugF("updated orders(%d) for tx %s", len(orders), txid)
+	} else {
+		if err = session.Where("t_x = ?", txid).In("asset", assets).Find(&orders); err != nil {
+			return nil, err
+		}
+
+		if len(orders) != 0 {
+			watcher.DebugF("orders(%d) of tx %s confirmed already, skip notifications", len(orders), txid)
+
+			return orders, session.Commit()
+		}
+
+		var news []*neodb.Order
+		wallet := new(neodb.Wallet)
+
+		for _, tx := range neoTxs {
+
+			count, err := session.Where(`"address" = ? or "address" = ?`, tx.From, tx.To).Count(wallet)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if count == 0 {
+				invoiced, err := watcher.hasOpenInvoice(tx.To, tx.Asset)
+
+				if err != nil {
+					return nil, err
+				}
+
+				if invoiced {
+					count++
+				}
+			}
+
+			if count == 0 {
+				watched, err := watcher.hasOutgoingAlert(tx.From)
+
+				if err != nil {
+					return nil, err
+				}
+
+				if watched {
+					count++
+				}
+			}
+
+			if count == 0 {
+				subscribed, err := watcher.isSubscribed(tx)
+
+				if err != nil {
+					return nil, err
+				}
+
+				if subscribed {
+					count++
+				}
+			}
+
+			if count > 0 {
+
+				order := new(neodb.Order)
+
+				order.Asset = tx.Asset
+				order.From = tx.From
+				order.To = tx.To
+				order.TX = tx.TX
+				order.Value = watcher.assets.formatValue(tx.Asset, tx.Value)
+				order.CreateTime = tx.CreateTime
+				order.ConfirmTime = &tx.CreateTime
+				order.Block = int64(tx.Block)
+				news = append(news, order)
+			}
+		}
+
+		if len(news) == 0 {
+			return nil, session.Commit()
+		}
+
+		if _, err = session.Insert(&news); err != nil {
+			return nil, err
+		}
+
+		if err = session.Where("t_x = ?", txid).In("asset", assets).Find(&orders); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = watcher.notify(session, orders, created); err != nil {
+		return nil, err
+	}
+
+	if err = watcher.alert(session, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, session.Commit()
+}
+
+// confirmed apply the confirmed orders to the address stats and the invoices. They run after
+// the commit of saveOrders, each in its own transactions, and are idempotent: the stats count
+// a tx once per address and asset, and an order pays at most one invoice. saveOrders returns
+// the orders of a replayed tx, so a delivery of the tx after a crash in between completes them
+func (watcher *TxWatcher) confirmed(orders []*neodb.Order) error {
+	if err := applyStats(watcher.db, orders, watcher.assets); err != nil {
+		return err
+	}
+
+	return watcher.matchInvoices(orders)
+}
+
+// notify queue the notifications of the confirmed orders to the users watching the from or
+// to address within session, honoring the notification preferences of each wallet
+func (watcher *TxWatcher) notify(session *xorm.Session, orders []*neodb.Order, created bool) error {
+	for _, order := range orders {
+		wallets := make([]*neodb.Wallet, 0)
+
+		err := watcher.db.Where(`"address" = ? or "address" = ?`, order.From, order.To).Find(&wallets)
+
+		if err != nil {
+			return err
+		}
 
-		count, err := watcher.db.Where(`"address" = ? or "address" = ?`, tx.From, tx.To).Count(wallet)
+		var settings []*WalletSettings
+
+		err = watcher.db.Where(`"address" = ? or "address" = ?`, order.From, order.To).Find(&settings)
 
 		if err != nil {
 			return err
 		}
 
-		if count > 0 {
+		userSettings := make(map[string]*WalletSettings)
+
+		for _, setting := range settings {
+			userSettings[setting.UserID+"|"+setting.Address] = setting
+		}
+
+		value := watcher.assets.formatValue(order.Asset, order.Value)
+		name := watcher.assets.name(order.Asset)
+
+		// the wallet owners, muted or not, are not notified again as subscribers
+		notified := make(map[string]bool)
+
+		for _, wallet := range wallets {
+			notified[wallet.UserID] = true
+
+			if setting, ok := userSettings[wallet.UserID+"|"+wallet.Address]; ok && !setting.accepts(order, watcher.assets) {
+				watcher.DebugF("skip push order %s to %s by wallet %s settings", order.TX, wallet.UserID, wallet.Address)
+				continue
+			}
+
+			labels, err := watcher.labels.labels(wallet.UserID, []string{order.From, order.To})
+
+			if err != nil {
+				return err
+			}
+
+			data := &PushData{
+				Address:      wallet.Address,
+				Counterparty: order.From,
+				Asset:        order.Asset,
+				AssetName:    name,
+				Value:        value,
+				TX:           order.TX,
+			}
+
+			event := pushReceived
+
+			if wallet.Address != order.To {
+				data.Counterparty = order.To
+				event = pushSent
+
+				if created {
+					event = pushConfirmed
+				}
+			}
 
-			order := new(neodb.Order)
+			data.Label = labels[data.Counterparty]
 
-			order.Asset = tx.Asset
-			order.From = tx.From
-			order.To = tx.To
-			order.TX = tx.TX
-			order.Value = tx.Value
-			order.CreateTime = tx.CreateTime
-			order.ConfirmTime = &tx.CreateTime
-			order.Block = int64(tx.Block)
-			orders = append(orders, order)
+			if err := watcher.queue(session, wallet.UserID, wallet.Address, event, data); err != nil {
+				return err
+			}
+		}
+
+		if err := watcher.notifySubscribers(session, order, notified); err != nil {
+			return err
 		}
 	}
 
-	if len(orders) > 0 {
-		_, err = watcher.db.Insert(&orders)
+	return nil
+}
+
+// queue render the message of event in the locale of the user and write it to the outbox
+// of the user channel within session
+func (watcher *TxWatcher) queue(session *xorm.Session, userid string, address string, event string, data *PushData) error {
+	preferences, err := userPreferences(watcher.db, userid, address)
 
+	if err != nil {
 		return err
 	}
 
-	return nil
+	message, err := watcher.templates.render(preferences.Locale, event, data)
+
+	if err != nil {
+		return err
+	}
+
+	channel := preferences.Channel
+
+	if channel == "" {
+		channel = channelPush
+	}
+
+	// the tx notifications are keyed so a replayed tx does not notify twice
+	key := ""
+
+	if data.TX != "" {
+		key = fmt.Sprintf("%s|%s|%s|%s|%s", event, data.TX, address, data.Asset, data.InvoiceID)
+	}
+
+	return queueNotification(session, watcher.db.DatabaseTZ, &Notification{
+		UserID:   userid,
+		Channel:  channel,
+		Target:   preferences.Target,
+		Category: pushCategories[event],
+		Event:    event,
+		Message:  message,
+		TX:       data.TX,
+		Key:      key,
+	})
 }
@@ -0,0 +1,126 @@
+package orderservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/inwecrypto/neo-order-service/neo"
+)
+
+// validationError invalid request parameter
+type validationError struct {
+	Field   string
+	Message string
+}
+
+func (err *validationError) Error() string {
+	return fmt.Sprintf("invalid %s, %s", err.Field, err.Message)
+}
+
+func newValidationError(field string, format string, args ...interface{}) *validationError {
+	return &validationError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// validateAddress check address is a NEO Base58Check address
+func validateAddress(field string, address string) (string, error) {
+	address = strings.TrimSpace(address)
+
+	if address == "" {
+		return "", newValidationError(field, "address required")
+	}
+
+	if _, err := neo.DecodeAddress(address); err != nil {
+		return "", newValidationError(field, "%s", err)
+	}
+
+	return address, nil
+}
+
+// normalizeTxID check tx is a 32 bytes hex tx id, and normalize it to lower case with 0x prefix
+func normalizeTxID(field string, tx string) (string, error) {
+	tx = strings.ToLower(strings.TrimSpace(tx))
+	tx = strings.TrimPrefix(tx, "0x")
+
+	if len(tx) != 64 || !isHex(tx) {
+		return "", newValidationError(field, "tx id must be 32 bytes hex string")
+	}
+
+	return "0x" + tx, nil
+}
+
+// validateAsset check asset is registered and normalize the asset id
+func (service *HTTPServer) validateAsset(field string, id string) (string, error) {
+	asset, ok := service.assets.get(id)
+
+	if !ok {
+		return "", newValidationError(field, "unknown asset %s", id)
+	}
+
+	return asset.Asset, nil
+}
+
+// validateAssets validate asset id list
+func (service *HTTPServer) validateAssets(field string, ids []string) ([]string, error) {
+	assets := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		asset, err := service.validateAsset(field, id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// validateOrder validate and normalize create order request
+func (service *HTTPServer) validateOrder(order *Order) (err error) {
+	if order.Tx, err = normalizeTxID("tx", order.Tx); err != nil {
+		return err
+	}
+
+	if order.From, err = validateAddress("from", order.From); err != nil {
+		return err
+	}
+
+	if order.To, err = validateAddress("to", order.To); err != nil {
+		return err
+	}
+
+	if order.Asset, err = service.validateAsset("asset", order.Asset); err != nil {
+		return err
+	}
+
+	value, err := service.assets.parseValue(order.Asset, order.Value)
+
+	if err != nil {
+		return newValidationError("value", "%s", err)
+	}
+
+	if !value.IsPositive() {
+		return newValidationError("value", "value must be positive")
+	}
+
+	order.Value = value.String()
+
+	context, err := service.normalizeContext(order.Context)
+
+	if err != nil {
+		return err
+	}
+
+	order.Context = nil
+
+	if context != nil {
+		order.Context = json.RawMessage(*context)
+	}
+
+	return nil
+}
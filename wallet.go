@@ -0,0 +1,255 @@
+package orderservice
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/inwecrypto/neodb"
+)
+
+// WalletSettings user settings of a registered wallet, companion of neodb.Wallet
+type WalletSettings struct {
+	ID         int64     `xorm:"pk autoincr"`
+	UserID     string    `xorm:"notnull unique(userid_address)"`
+	Address    string    `xorm:"notnull unique(userid_address)"`
+	Label      string    `xorm:"notnull default ''"`
+	Muted      bool      `xorm:"notnull default false"`
+	Assets     []string  `xorm:"json"`
+	MinValue   string    `xorm:"notnull default ''"`
+	Locale     string    `xorm:"notnull default ''"` // push message locale, the default locale if empty
+	Channel    string    `xorm:"notnull default ''"` // notification channel, aliyun push if empty
+	Target     string    `xorm:"notnull default ''"` // email address or telegram chat of the channel
+	UpdateTime time.Time `xorm:"TIMESTAMP notnull updated"`
+}
+
+// TableName xorm table name
+func (table *WalletSettings) TableName() string {
+	return "neo_wallet_settings"
+}
+
+// accepts check if the order should be pushed to the wallet owner
+func (settings *WalletSettings) accepts(order *neodb.Order, assets *assetRegistry) bool {
+	if settings.Muted {
+		return false
+	}
+
+	if len(settings.Assets) > 0 {
+		var found bool
+
+		for _, asset := range settings.Assets {
+			if asset == order.Asset {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if settings.MinValue != "" {
+		min, err := ParseDecimal(settings.MinValue)
+
+		if err != nil {
+			return true
+		}
+
+		value, err := assets.parseValue(order.Asset, order.Value)
+
+		if err != nil {
+			return true
+		}
+
+		return value.Cmp(min) >= 0
+	}
+
+	return true
+}
+
+// WalletPreferences wallet notification preferences
+type WalletPreferences struct {
+	Muted    bool     `json:"muted"`              // no push for the wallet at all
+	Assets   []string `json:"assets,omitempty"`   // push only orders of these assets, all if empty
+	MinValue string   `json:"minValue,omitempty"` // push only orders of at least this value
+}
+
+// WalletUpdate update wallet label and preferences request
+type WalletUpdate struct {
+	Label        string             `json:"label"`
+	Locale       string             `json:"locale"`
+	Channel      string             `json:"channel"`
+	Target       string             `json:"target"`
+	Token        string             `json:"token"` // confirmation token of a target not stored yet
+	Notification *WalletPreferences `json:"notification"`
+}
+
+// validateWalletUpdate validate and normalize wallet update request
+func (service *HTTPServer) validateWalletUpdate(update *WalletUpdate) (err error) {
+	if len(update.Label) > 64 {
+		return newValidationError("label", "label must be at most 64 chars")
+	}
+
+	if update.Locale, err = service.templates.validateLocale("locale", update.Locale); err != nil {
+		return err
+	}
+
+	if update.Channel, err = validateChannel(service.notifiers, update.Channel, update.Target); err != nil {
+		return err
+	}
+
+	if update.Notification == nil {
+		update.Notification = new(WalletPreferences)
+	}
+
+	preferences := update.Notification
+
+	if preferences.Assets, err = service.validateAssets("notification.assets", preferences.Assets); err != nil {
+		return err
+	}
+
+	if preferences.MinValue != "" {
+		if _, err := ParseDecimal(preferences.MinValue); err != nil {
+			return newValidationError("notification.minValue", "%s", err)
+		}
+	}
+
+	return nil
+}
+
+func newWallet(wallet *neodb.Wallet, settings *WalletSettings) *Wallet {
+	result := &Wallet{
+		Address:      wallet.Address,
+		UserID:       wallet.UserID,
+		CreateTime:   wallet.CreateTime.Format(time.RFC3339Nano),
+		Notification: new(WalletPreferences),
+	}
+
+	if settings != nil {
+		result.Label = settings.Label
+		result.Locale = settings.Locale
+		result.Channel = settings.Channel
+		result.Target = settings.Target
+		result.Notification.Muted = settings.Muted
+		result.Notification.Assets = settings.Assets
+		result.Notification.MinValue = settings.MinValue
+	}
+
+	return result
+}
+
+// listWallets list the wallets registered by userid with their settings
+func (service *HTTPServer) listWallets(userid string) ([]*Wallet, error) {
+	var wallets []*neodb.Wallet
+
+	if err := service.db.Where("user_i_d = ?", userid).Asc("create_time", "id").Find(&wallets); err != nil {
+		return nil, err
+	}
+
+	var settings []*WalletSettings
+
+	if err := service.db.Where("user_i_d = ?", userid).Find(&settings); err != nil {
+		return nil, err
+	}
+
+	addressSettings := make(map[string]*WalletSettings)
+
+	for _, setting := range settings {
+		addressSettings[setting.Address] = setting
+	}
+
+	result := make([]*Wallet, 0, len(wallets))
+
+	for _, wallet := range wallets {
+		result = append(result, newWallet(wallet, addressSettings[wallet.Address]))
+	}
+
+	return result, nil
+}
+
+// updateWallet replace the label and notification preferences of a registered wallet
+func (service *HTTPServer) updateWallet(userid string, address string, update *WalletUpdate) (*Wallet, error) {
+	wallet := new(neodb.Wallet)
+
+	ok, err := service.db.Where(`user_i_d = ? and "address" = ?`, userid, address).Get(wallet)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errNotFound("wallet %s of user %s not found", address, userid)
+	}
+
+	settings := &WalletSettings{
+		UserID:   userid,
+		Address:  address,
+		Label:    update.Label,
+		Locale:   update.Locale,
+		Channel:  update.Channel,
+		Target:   update.Target,
+		Muted:    update.Notification.Muted,
+		Assets:   update.Notification.Assets,
+		MinValue: update.Notification.MinValue,
+	}
+
+	updated, err := service.db.
+		Where(`user_i_d = ? and "address" = ?`, userid, address).
+		Cols("label", "locale", "channel", "target", "muted", "assets", "min_value", "update_time").
+		Update(settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if updated == 0 {
+		if _, err := service.db.Insert(settings); err != nil {
+			return nil, err
+		}
+	}
+
+	return newWallet(wallet, settings), nil
+}
+
+// deleteWallets unregister all the wallets of userid, returns the number of wallets removed
+func (service *HTTPServer) deleteWallets(userid string) (int64, error) {
+	deleted, err := service.db.Where("user_i_d = ?", userid).Delete(new(neodb.Wallet))
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := service.db.Where("user_i_d = ?", userid).Delete(new(WalletSettings)); err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// registeredWallet authorize the :userid and :address params of the request and check
+// the wallet is registered, returns the validated address
+func (service *HTTPServer) registeredWallet(ctx *gin.Context) (string, error) {
+	userid := ctx.Param("userid")
+
+	if err := service.authorizeUser(ctx, userid); err != nil {
+		return "", err
+	}
+
+	address, err := validateAddress("address", ctx.Param("address"))
+
+	if err != nil {
+		return "", err
+	}
+
+	count, err := service.db.Where(`user_i_d = ? and "address" = ?`, userid, address).Count(new(neodb.Wallet))
+
+	if err != nil {
+		return "", err
+	}
+
+	if count == 0 {
+		return "", errNotFound("wallet %s of user %s not found", address, userid)
+	}
+
+	return address, nil
+}